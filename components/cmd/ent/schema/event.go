@@ -0,0 +1,37 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package schema
+
+import (
+	"time"
+
+	"github.com/facebook/ent"
+	"github.com/facebook/ent/schema/field"
+)
+
+// Event is a docker event of a container or an image managed by aid
+type Event struct {
+	ent.Schema
+}
+
+// Fields of the event
+func (Event) Fields() []ent.Field {
+	return []ent.Field{
+		// type is container or image
+		field.String("type"),
+		// action is e.g. start, die, oom or destroy
+		field.String("action"),
+		// actor is the unique id of the container or image
+		field.String("actor"),
+		field.String("name").
+			Optional(),
+		// attributes are the labels and details of the event, e.g. exitCode
+		field.JSON("attributes", map[string]string{}).
+			Optional(),
+		field.Time("created_at").
+			Default(time.Now),
+	}
+}
@@ -47,12 +47,27 @@ func (Container) Fields() []ent.Field {
 		field.String("port"),
 		field.Bool("running").Default(false),
 		field.Time("created_at").Default(time.Now),
+		// name is the unique name of the container in docker
+		field.String("name").Optional(),
+		field.String("bind_address").Optional(),
+		// env holds KEY=VALUE pairs
+		field.Strings("env").Optional(),
+		// volumes holds [host path]:[container path][:ro] bindings with absolute host paths
+		field.Strings("volumes").Optional(),
+		// memory is the memory limit in bytes, 0 is unlimited
+		field.Int64("memory").Optional(),
+		// cpus is the number of CPUs, 0 is unlimited
+		field.Float("cpus").Optional(),
+		field.String("restart").Optional(),
+		// health is starting, healthy or unhealthy while the container is running
+		field.String("health").Optional(),
 	}
 }
 
 // Edges of Container.
 func (Container) Edges() []ent.Edge {
 	return []ent.Edge{
-		edge.To("image", Image.Type),
+		// many containers can be created from the same image
+		edge.To("image", Image.Type).Unique(),
 	}
 }
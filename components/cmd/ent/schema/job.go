@@ -0,0 +1,41 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package schema
+
+import (
+	"time"
+
+	"github.com/facebook/ent"
+	"github.com/facebook/ent/schema/field"
+)
+
+// Job schema
+type Job struct {
+	ent.Schema
+}
+
+// Fields of the job.
+func (Job) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("uid").
+			Unique(),
+		field.String("kind"),
+		field.String("target"),
+		field.String("state").
+			Default("queued"),
+		field.String("error").
+			Optional(),
+		field.String("logpath"),
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("started_at").
+			Optional().
+			Nillable(),
+		field.Time("finished_at").
+			Optional().
+			Nillable(),
+	}
+}
@@ -24,6 +24,12 @@ func (Repository) Fields() []ent.Field {
 			Unique(),
 		field.String("localpath").
 			Unique(),
+		field.String("version").
+			Optional(),
+		field.String("ref").
+			Optional(),
+		field.String("commit").
+			Optional(),
 		field.Time("created_at").
 			Default(time.Now),
 	}
@@ -33,5 +39,7 @@ func (Repository) Fields() []ent.Field {
 func (Repository) Edges() []ent.Edge {
 	return []ent.Edge{
 		edge.To("solvers", Solver.Type),
+		edge.To("dependencies", Repository.Type).
+			From("dependents"),
 	}
 }
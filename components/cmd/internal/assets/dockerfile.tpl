@@ -17,6 +17,9 @@ RUN {{Setup|safe}}
 
 WORKDIR /app
 
+HEALTHCHECK --interval=10s --timeout=3s --start-period=30s --retries=3 \
+    CMD python -c "import json, sys, urllib.request; sys.exit(0 if json.load(urllib.request.urlopen('http://127.0.0.1:8080/', timeout=2)).get('status') == 'OK' else 1)"
+
 ENTRYPOINT ["gunicorn"]
 
 CMD ["runner_{{Solvername}}:aidserver","-b", "0.0.0.0:8080","-k","uvicorn.workers.UvicornWorker"]
\ No newline at end of file
@@ -6,30 +6,12 @@
 package configuration
 
 import (
+	"path/filepath"
+
 	"github.com/BurntSushi/toml"
 	"github.com/autoai-org/aid/internal/utilities"
 )
 
-// LoadSolversFromConfig reads the config string and returns the objects
-func LoadSolversFromConfig(tomlString string) PackageConfig {
-	var packageConfig PackageConfig
-	_, err := toml.Decode(tomlString, &packageConfig)
-	if err != nil {
-		utilities.Formatter.Error("Cannot load solvers from toml string: " + err.Error())
-	}
-	return packageConfig
-}
-
-// LoadPackageFromConfig reads the config string and returns a PackageConfig
-func LoadPackageFromConfig(tomlString string) PackageConfig {
-	var packageConfig PackageConfig
-	_, err := toml.Decode(tomlString, &packageConfig)
-	if err != nil {
-		utilities.Formatter.Error("Cannot load solvers from toml string: " + err.Error())
-	}
-	return packageConfig
-}
-
 // LoadPretrainedsFromConfig reads the pretrained config string and returns a Pretraineds object
 func LoadPretrainedsFromConfig(tomlString string) Pretraineds {
 	var pretraineds Pretraineds
@@ -39,3 +21,20 @@ func LoadPretrainedsFromConfig(tomlString string) Pretraineds {
 	}
 	return pretraineds
 }
+
+// validatePretrainedFile checks pretrained.toml in the package folder, if there is one
+func validatePretrainedFile(packageFolder string) ([]ManifestIssue, error) {
+	pretrainedPath := filepath.Join(packageFolder, PretrainedFile)
+	if !utilities.IsFileExists(pretrainedPath) {
+		return nil, nil
+	}
+	tomlString, err := utilities.ReadFileContent(pretrainedPath)
+	if err != nil {
+		return nil, utilities.NewError(utilities.PackageError, "cannot read "+pretrainedPath, err)
+	}
+	var pretraineds Pretraineds
+	if _, err := toml.Decode(tomlString, &pretraineds); err != nil {
+		return []ManifestIssue{{File: PretrainedFile, Message: err.Error()}}, nil
+	}
+	return pretraineds.issues(indexManifestLines(tomlString)), nil
+}
@@ -0,0 +1,275 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package configuration
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// ManifestFile is the name of the manifest in every package
+const ManifestFile = "aid.toml"
+
+var (
+	// namePattern matches package and solver names, they are used in file and image names
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	// classPattern matches [package]/[file]/[Class]
+	classPattern = regexp.MustCompile(`^[A-Za-z_][\w.]*/[A-Za-z_]\w*/[A-Za-z_]\w*$`)
+	// parseErrorLinePattern extracts the line number from toml parse errors
+	parseErrorLinePattern = regexp.MustCompile(`Near line (\d+)`)
+	tableHeaderPattern    = regexp.MustCompile(`^\[\[?\s*([\w.-]+)\s*\]\]?`)
+	keyPattern            = regexp.MustCompile(`^([\w-]+)\s*=`)
+)
+
+// Manifest is the schema of aid.toml
+type Manifest struct {
+	Package      PackageManifest      `toml:"package"`
+	Solvers      []SolverManifest     `toml:"solvers"`
+	Dependencies []DependencyManifest `toml:"dependencies"`
+	Runtime      RuntimeManifest      `toml:"runtime"`
+	// lines maps tables and keys to the line they are defined on
+	lines map[string]int
+}
+
+// Line returns the line a table or a key is defined on, e.g. dependencies.0.version,
+// it is 0 if it is unknown
+func (manifest *Manifest) Line(key string) int {
+	return manifest.lines[key]
+}
+
+// PackageManifest is the [package] table of aid.toml
+type PackageManifest struct {
+	Name    string `toml:"name"`
+	Vendor  string `toml:"vendor"`
+	Tagline string `toml:"tagline"`
+}
+
+// SolverManifest is a [[solvers]] entry of aid.toml
+type SolverManifest struct {
+	Name string `toml:"name"`
+	// Class is the solver class in the form of [package]/[file]/[Class]
+	Class string `toml:"class"`
+}
+
+// DependencyManifest is a [[dependencies]] entry of aid.toml
+type DependencyManifest struct {
+	// Package is either [vendor]/[name] in the registry or a git address
+	Package string `toml:"package"`
+	// Version is a version constraint for registry packages, e.g. ">=1.2, <2"
+	Version string `toml:"version"`
+	// Ref is the branch, tag or commit of git packages
+	Ref string `toml:"ref"`
+}
+
+// ManifestIssue is a problem found in aid.toml, or in File if it is set,
+// Line is 0 if it is unknown
+type ManifestIssue struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning"`
+}
+
+func (issue ManifestIssue) String() string {
+	location := ManifestFile
+	if issue.File != "" {
+		location = issue.File
+	}
+	if issue.Line > 0 {
+		location += ":" + strconv.Itoa(issue.Line)
+	}
+	if issue.Field != "" {
+		location += ": " + issue.Field
+	}
+	return location + ": " + issue.Message
+}
+
+// ParseManifest decodes and validates aid.toml. The manifest is nil if
+// it cannot be decoded, warnings do not make the manifest invalid.
+func ParseManifest(tomlString string) (*Manifest, []ManifestIssue) {
+	var manifest Manifest
+	meta, err := toml.Decode(tomlString, &manifest)
+	if err != nil {
+		issue := ManifestIssue{Message: err.Error()}
+		if matches := parseErrorLinePattern.FindStringSubmatch(err.Error()); matches != nil {
+			issue.Line, _ = strconv.Atoi(matches[1])
+		}
+		return nil, []ManifestIssue{issue}
+	}
+	lines := indexManifestLines(tomlString)
+	var issues []ManifestIssue
+	report := func(key string, field string, message string) {
+		issues = append(issues, ManifestIssue{Line: lines[key], Field: field, Message: message})
+	}
+	if !meta.IsDefined("package") {
+		report("", "package", "the [package] table is required")
+	} else if manifest.Package.Name == "" {
+		report("package", "package.name", "is required")
+	} else if !namePattern.MatchString(manifest.Package.Name) {
+		report("package.name", "package.name", "should only contain letters, digits, '_', '.' and '-'")
+	}
+	if len(manifest.Solvers) == 0 {
+		report("", "solvers", "at least one [[solvers]] entry is required")
+	}
+	solverNames := make(map[string]int)
+	for idx, solver := range manifest.Solvers {
+		table := "solvers." + strconv.Itoa(idx)
+		field := fmt.Sprintf("solvers[%d]", idx)
+		switch {
+		case solver.Name == "":
+			report(table, field+".name", "is required")
+		case !namePattern.MatchString(solver.Name):
+			report(table+".name", field+".name", "should only contain letters, digits, '_', '.' and '-'")
+		default:
+			if first, ok := solverNames[solver.Name]; ok {
+				report(table+".name", field+".name", fmt.Sprintf("%q is already used by solvers[%d]", solver.Name, first))
+			} else {
+				solverNames[solver.Name] = idx
+			}
+		}
+		switch {
+		case solver.Class == "":
+			report(table, field+".class", "is required")
+		case !classPattern.MatchString(solver.Class):
+			report(table+".class", field+".class", fmt.Sprintf("should be [package]/[file]/[Class], got %q", solver.Class))
+		}
+	}
+	// the addresses and versions of dependencies are checked by the workflows
+	for idx, dependency := range manifest.Dependencies {
+		if dependency.Package == "" {
+			report("dependencies."+strconv.Itoa(idx), fmt.Sprintf("dependencies[%d].package", idx), "is required")
+		}
+	}
+	manifest.Runtime.validate(report)
+	for idx, volume := range manifest.Runtime.Volumes {
+		// packages may only share their own files with the container
+		hostPath, _, _, err := ParseVolume(volume)
+		if err == nil && !InsidePackage(hostPath) {
+			report("runtime.volumes", "runtime.volumes["+strconv.Itoa(idx)+"]", "the host path "+strconv.Quote(hostPath)+" should be inside the package folder")
+		}
+	}
+	for _, key := range meta.Undecoded() {
+		issues = append(issues, ManifestIssue{
+			Line:    lines["first:"+key.String()],
+			Field:   key.String(),
+			Message: "unknown field",
+			Warning: true,
+		})
+	}
+	manifest.lines = lines
+	return &manifest, issues
+}
+
+// ReadManifest reads aid.toml in the package folder and returns an error
+// listing all issues if it is invalid
+func ReadManifest(packageFolder string) (*Manifest, error) {
+	manifestPath := filepath.Join(packageFolder, ManifestFile)
+	tomlString, err := utilities.ReadFileContent(manifestPath)
+	if err != nil {
+		return nil, utilities.NewError(utilities.PackageError, "cannot read "+manifestPath, err)
+	}
+	manifest, issues := ParseManifest(tomlString)
+	if err := IssuesError(manifestPath, issues); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// IssuesError returns an error listing the issues of the manifest at
+// manifestPath, it is nil if there are only warnings
+func IssuesError(manifestPath string, issues []ManifestIssue) error {
+	var messages []string
+	for _, issue := range issues {
+		if !issue.Warning {
+			messages = append(messages, issue.String())
+		}
+	}
+	if len(messages) > 0 {
+		return utilities.NewError(utilities.PackageError, "invalid "+manifestPath+"\n"+strings.Join(messages, "\n"), nil)
+	}
+	return nil
+}
+
+// ValidatePackage checks aid.toml in the package folder, and warns about solver
+// classes whose source file, [package]/[file].py, cannot be found. The
+// manifest is nil if it cannot be decoded.
+func ValidatePackage(packageFolder string) (*Manifest, []ManifestIssue, error) {
+	manifestPath := filepath.Join(packageFolder, ManifestFile)
+	tomlString, err := utilities.ReadFileContent(manifestPath)
+	if err != nil {
+		return nil, nil, utilities.NewError(utilities.PackageError, "cannot read "+manifestPath, err)
+	}
+	manifest, issues := ParseManifest(tomlString)
+	if manifest == nil {
+		return nil, issues, nil
+	}
+	pretrainedIssues, err := validatePretrainedFile(packageFolder)
+	if err != nil {
+		return nil, nil, err
+	}
+	issues = append(issues, pretrainedIssues...)
+	for idx, solver := range manifest.Solvers {
+		if !classPattern.MatchString(solver.Class) {
+			continue
+		}
+		classInfo := strings.Split(solver.Class, "/")
+		sourceFile := filepath.Join(packageFolder, classInfo[0], classInfo[1]+".py")
+		if !utilities.IsFileExists(sourceFile) {
+			issues = append(issues, ManifestIssue{
+				Line:    manifest.Line("solvers." + strconv.Itoa(idx) + ".class"),
+				Field:   fmt.Sprintf("solvers[%d].class", idx),
+				Message: "cannot find " + filepath.Join(classInfo[0], classInfo[1]+".py"),
+				Warning: true,
+			})
+		}
+	}
+	return manifest, issues, nil
+}
+
+// indexManifestLines maps tables and keys to the line they are defined on.
+// Array tables are indexed, e.g. solvers.1.name, and the first definition of
+// every key is stored without indices as first:solvers.name.
+func indexManifestLines(tomlString string) map[string]int {
+	lines := make(map[string]int)
+	arrayCounts := make(map[string]int)
+	table, plainTable := "", ""
+	scanner := bufio.NewScanner(strings.NewReader(tomlString))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if matches := tableHeaderPattern.FindStringSubmatch(line); matches != nil {
+			plainTable = matches[1]
+			table = plainTable
+			if strings.HasPrefix(line, "[[") {
+				table = plainTable + "." + strconv.Itoa(arrayCounts[plainTable])
+				arrayCounts[plainTable]++
+			}
+			lines[table] = lineNumber
+			if _, ok := lines["first:"+plainTable]; !ok {
+				lines["first:"+plainTable] = lineNumber
+			}
+			continue
+		}
+		if matches := keyPattern.FindStringSubmatch(line); matches != nil {
+			key, plainKey := matches[1], matches[1]
+			if table != "" {
+				key, plainKey = table+"."+key, plainTable+"."+plainKey
+			}
+			lines[key] = lineNumber
+			if _, ok := lines["first:"+plainKey]; !ok {
+				lines["first:"+plainKey] = lineNumber
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package configuration
+
+import (
+	"strings"
+	"testing"
+)
+
+const validManifest = `[package]
+name = "demo"
+vendor = "aidmodels"
+
+[[solvers]]
+name = "detector"
+class = "demo/solver/Detector"
+`
+
+func TestParseManifest(t *testing.T) {
+	// issue is the line, the field and whether it is a warning
+	type issue struct {
+		line    int
+		field   string
+		warning bool
+	}
+	tests := []struct {
+		name     string
+		manifest string
+		want     []issue
+		// undecoded is true if the manifest cannot be decoded at all
+		undecoded bool
+	}{
+		{name: "valid", manifest: validManifest},
+		{name: "syntax error", manifest: validManifest + "\n[runtime\nmemory = 1\n", undecoded: true, want: []issue{{line: 9}}},
+		{name: "missing package", manifest: "[[solvers]]\nname = \"a\"\nclass = \"demo/solver/A\"\n", want: []issue{{line: 0, field: "package"}}},
+		{name: "invalid names", manifest: strings.Replace(strings.Replace(validManifest, `"demo"`, `"de mo"`, 1), `"detector"`, `"../detector"`, 1),
+			want: []issue{{line: 2, field: "package.name"}, {line: 6, field: "solvers[0].name"}}},
+		{name: "duplicate solver", manifest: validManifest + "\n[[solvers]]\nname = \"detector\"\nclass = \"demo/solver/Other\"\n",
+			want: []issue{{line: 10, field: "solvers[1].name"}}},
+		{name: "invalid class", manifest: validManifest + "\n[[solvers]]\nname = \"other\"\nclass = \"demo.Other\"\n",
+			want: []issue{{line: 11, field: "solvers[1].class"}}},
+		{name: "missing class", manifest: validManifest + "\n[[solvers]]\nname = \"other\"\n",
+			want: []issue{{line: 9, field: "solvers[1].class"}}},
+		{name: "dependency without package", manifest: validManifest + "\n[[dependencies]]\nversion = \"^1.0\"\n",
+			want: []issue{{line: 9, field: "dependencies[0].package"}}},
+		{name: "unknown field", manifest: validManifest + "license = \"MIT\"\n",
+			want: []issue{{line: 8, field: "solvers.license", warning: true}}},
+		{name: "invalid runtime", manifest: validManifest + "\n[runtime]\nmemory = \"lots\"\nvolumes = [\"models\"]\nrestart = \"sometimes\"\n",
+			want: []issue{{line: 11, field: "runtime.volumes[0]"}, {line: 10, field: "runtime.memory"}, {line: 12, field: "runtime.restart"}}},
+		{name: "volume outside of the package", manifest: validManifest + "\n[runtime]\nvolumes = [\"../shared:/shared\", \"data/../../..:/data\", \"/etc:/etc:ro\", \"pretrained:/models:ro\", \"data/..:/package\"]\n",
+			want: []issue{{line: 10, field: "runtime.volumes[0]"}, {line: 10, field: "runtime.volumes[1]"}, {line: 10, field: "runtime.volumes[2]"}}},
+	}
+	for _, test := range tests {
+		manifest, issues := ParseManifest(test.manifest)
+		if (manifest == nil) != test.undecoded {
+			t.Errorf("%s: manifest = %v, want decoded %v", test.name, manifest, !test.undecoded)
+		}
+		var got []issue
+		for _, found := range issues {
+			got = append(got, issue{line: found.Line, field: found.Field, warning: found.Warning})
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: issues = %+v, want %+v", test.name, issues, test.want)
+			continue
+		}
+		for idx := range got {
+			if got[idx] != test.want[idx] {
+				t.Errorf("%s: issues = %+v, want %+v", test.name, issues, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestManifestLine(t *testing.T) {
+	manifest, issues := ParseManifest(validManifest + "\n[[solvers]]\nname = \"other\"\nclass = \"demo/solver/Other\"\n\n[[dependencies]]\npackage = \"aidmodels/base\"\nversion = \"^1.0\"\n")
+	if manifest == nil || len(issues) > 0 {
+		t.Fatalf("ParseManifest = %v, %v", manifest, issues)
+	}
+	lines := map[string]int{
+		"package":                1,
+		"package.name":           2,
+		"solvers.0.class":        7,
+		"solvers.1":              9,
+		"solvers.1.name":         10,
+		"dependencies.0.version": 15,
+		"runtime":                0,
+	}
+	for key, want := range lines {
+		if got := manifest.Line(key); got != want {
+			t.Errorf("Line(%q) = %d, want %d", key, got, want)
+		}
+	}
+}
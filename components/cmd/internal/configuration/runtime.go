@@ -0,0 +1,211 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package configuration
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/go-units"
+)
+
+// RestartPolicies are the restart policies supported by docker
+var RestartPolicies = []string{"no", "always", "unless-stopped", "on-failure"}
+
+// RuntimeManifest is the [runtime] table of aid.toml, the default
+// settings of all containers created from the images of the package
+type RuntimeManifest struct {
+	// Env maps environment variables to their values
+	Env map[string]string `toml:"env" json:"env,omitempty"`
+	// Volumes are [host path]:[container path][:ro], relative host paths are
+	// relative to the package folder, e.g. pretrained:/models:ro
+	Volumes []string `toml:"volumes" json:"volumes,omitempty"`
+	// Memory is the memory limit, e.g. 512m or 2g
+	Memory string `toml:"memory" json:"memory,omitempty"`
+	// CPUs is the number of CPUs the container may use, e.g. 1.5
+	CPUs float64 `toml:"cpus" json:"cpus,omitempty"`
+	// Restart is no, always, unless-stopped or on-failure[:max retries]
+	Restart string `toml:"restart" json:"restart,omitempty"`
+	// BindAddress is the host address the port is published on, e.g. 127.0.0.1
+	BindAddress string `toml:"bind_address" json:"bind_address,omitempty"`
+}
+
+// ParseMemory parses memory limits like 512m or 2g into bytes, empty is no limit
+func ParseMemory(memory string) (int64, error) {
+	if memory == "" {
+		return 0, nil
+	}
+	bytes, err := units.RAMInBytes(memory)
+	if err != nil {
+		return 0, err
+	}
+	if bytes < 0 {
+		return 0, errors.New("should not be negative")
+	}
+	return bytes, nil
+}
+
+// ParseRestartPolicy splits the restart policy into its name and the
+// maximum retries, which are only allowed for on-failure
+func ParseRestartPolicy(policy string) (string, int, error) {
+	if policy == "" {
+		return "", 0, nil
+	}
+	parts := strings.SplitN(policy, ":", 2)
+	known := false
+	for _, name := range RestartPolicies {
+		known = known || parts[0] == name
+	}
+	if !known {
+		return "", 0, errors.New("should be one of " + strings.Join(RestartPolicies, ", ") + ", got " + strconv.Quote(policy))
+	}
+	if len(parts) == 1 {
+		return parts[0], 0, nil
+	}
+	if parts[0] != "on-failure" {
+		return "", 0, errors.New("maximum retries are only supported by on-failure")
+	}
+	retries, err := strconv.Atoi(parts[1])
+	if err != nil || retries < 0 {
+		return "", 0, errors.New("invalid maximum retries " + strconv.Quote(parts[1]))
+	}
+	return parts[0], retries, nil
+}
+
+// ParseVolume splits [host path]:[container path][:ro|rw] into its parts
+func ParseVolume(volume string) (string, string, bool, error) {
+	parts := strings.Split(volume, ":")
+	readOnly := false
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			readOnly = true
+		case "rw":
+		default:
+			return "", "", false, errors.New("unknown mode " + strconv.Quote(parts[2]) + " in " + strconv.Quote(volume) + ", expected ro or rw")
+		}
+		parts = parts[:2]
+	}
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false, errors.New("expected [host path]:[container path][:ro], got " + strconv.Quote(volume))
+	}
+	if !strings.HasPrefix(parts[1], "/") {
+		return "", "", false, errors.New("the container path " + strconv.Quote(parts[1]) + " should be absolute")
+	}
+	return parts[0], parts[1], readOnly, nil
+}
+
+// ParseEnv splits KEY=VALUE into its key and value
+func ParseEnv(env string) (string, string, error) {
+	parts := strings.SplitN(env, "=", 2)
+	if len(parts) != 2 || !validEnvKey(parts[0]) {
+		return "", "", errors.New("expected KEY=VALUE, got " + strconv.Quote(env))
+	}
+	return parts[0], parts[1], nil
+}
+
+// ValidateBindAddress checks that the address is an ip address
+func ValidateBindAddress(address string) error {
+	if address != "" && net.ParseIP(address) == nil {
+		return errors.New("should be an ip address, got " + strconv.Quote(address))
+	}
+	return nil
+}
+
+func validEnvKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, "= \t\n")
+}
+
+// InsidePackage reports whether the relative host path of a volume stays
+// inside the package folder, symlinks are not resolved
+func InsidePackage(hostPath string) bool {
+	cleaned := filepath.Clean(hostPath)
+	return !filepath.IsAbs(hostPath) && cleaned != ".." && !strings.HasPrefix(cleaned, "../")
+}
+
+// ValidatePackageVolumes returns an error if a volume shares a host path
+// outside of the package folder. It applies to the settings of packages and
+// of remote clients, only the local user may share any host path.
+func (runtime RuntimeManifest) ValidatePackageVolumes() error {
+	var messages []string
+	for idx, volume := range runtime.Volumes {
+		hostPath, _, _, err := ParseVolume(volume)
+		if err == nil && !InsidePackage(hostPath) {
+			messages = append(messages, "runtime.volumes["+strconv.Itoa(idx)+"]: the host path "+strconv.Quote(hostPath)+" should be inside the package folder")
+		}
+	}
+	if len(messages) > 0 {
+		return errors.New(strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// Validate returns an error listing all invalid settings
+func (runtime RuntimeManifest) Validate() error {
+	var messages []string
+	runtime.validate(func(key string, field string, message string) {
+		messages = append(messages, field+": "+message)
+	})
+	if len(messages) > 0 {
+		return errors.New(strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// Merge returns the settings with the non-empty settings of overrides applied,
+// variables are merged by name and volumes are appended
+func (runtime RuntimeManifest) Merge(overrides RuntimeManifest) RuntimeManifest {
+	merged := runtime
+	merged.Env = make(map[string]string)
+	for _, env := range []map[string]string{runtime.Env, overrides.Env} {
+		for key, value := range env {
+			merged.Env[key] = value
+		}
+	}
+	merged.Volumes = append(append([]string{}, runtime.Volumes...), overrides.Volumes...)
+	if overrides.Memory != "" {
+		merged.Memory = overrides.Memory
+	}
+	if overrides.CPUs != 0 {
+		merged.CPUs = overrides.CPUs
+	}
+	if overrides.Restart != "" {
+		merged.Restart = overrides.Restart
+	}
+	if overrides.BindAddress != "" {
+		merged.BindAddress = overrides.BindAddress
+	}
+	return merged
+}
+
+// validate reports the issues of the [runtime] table
+func (runtime RuntimeManifest) validate(report func(key string, field string, message string)) {
+	for key := range runtime.Env {
+		if !validEnvKey(key) {
+			report("runtime.env", "runtime.env", "invalid variable name "+strconv.Quote(key))
+		}
+	}
+	for idx, volume := range runtime.Volumes {
+		if _, _, _, err := ParseVolume(volume); err != nil {
+			report("runtime.volumes", "runtime.volumes["+strconv.Itoa(idx)+"]", err.Error())
+		}
+	}
+	if _, err := ParseMemory(runtime.Memory); err != nil {
+		report("runtime.memory", "runtime.memory", "invalid memory limit "+strconv.Quote(runtime.Memory)+", expected e.g. 512m or 2g")
+	}
+	if runtime.CPUs < 0 {
+		report("runtime.cpus", "runtime.cpus", "should not be negative")
+	}
+	if _, _, err := ParseRestartPolicy(runtime.Restart); err != nil {
+		report("runtime.restart", "runtime.restart", err.Error())
+	}
+	if err := ValidateBindAddress(runtime.BindAddress); err != nil {
+		report("runtime.bind_address", "runtime.bind_address", err.Error())
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package configuration
+
+import "testing"
+
+func TestParseVolume(t *testing.T) {
+	tests := []struct {
+		volume        string
+		hostPath      string
+		containerPath string
+		readOnly      bool
+		wantErr       bool
+	}{
+		{volume: "pretrained:/models", hostPath: "pretrained", containerPath: "/models"},
+		{volume: "pretrained:/models:ro", hostPath: "pretrained", containerPath: "/models", readOnly: true},
+		{volume: "/data:/data:rw", hostPath: "/data", containerPath: "/data"},
+		{volume: "../shared:/shared", hostPath: "../shared", containerPath: "/shared"},
+		{volume: "pretrained", wantErr: true},
+		{volume: "pretrained:models", wantErr: true},
+		{volume: ":/models", wantErr: true},
+		{volume: "pretrained:", wantErr: true},
+		{volume: "pretrained:/models:rx", wantErr: true},
+		{volume: "a:/b:ro:extra", wantErr: true},
+	}
+	for _, test := range tests {
+		hostPath, containerPath, readOnly, err := ParseVolume(test.volume)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseVolume(%q) error = %v, want error %v", test.volume, err, test.wantErr)
+			continue
+		}
+		if hostPath != test.hostPath || containerPath != test.containerPath || readOnly != test.readOnly {
+			t.Errorf("ParseVolume(%q) = %q, %q, %v, want %q, %q, %v", test.volume,
+				hostPath, containerPath, readOnly, test.hostPath, test.containerPath, test.readOnly)
+		}
+	}
+}
+
+func TestInsidePackage(t *testing.T) {
+	tests := []struct {
+		hostPath string
+		want     bool
+	}{
+		{hostPath: "pretrained", want: true},
+		{hostPath: "./pretrained/models", want: true},
+		{hostPath: ".", want: true},
+		{hostPath: "data/..", want: true},
+		{hostPath: "..data", want: true},
+		{hostPath: "..", want: false},
+		{hostPath: "../shared", want: false},
+		{hostPath: "data/../../shared", want: false},
+		{hostPath: "./../shared", want: false},
+		{hostPath: "/etc", want: false},
+	}
+	for _, test := range tests {
+		if got := InsidePackage(test.hostPath); got != test.want {
+			t.Errorf("InsidePackage(%q) = %v, want %v", test.hostPath, got, test.want)
+		}
+	}
+}
+
+func TestValidatePackageVolumes(t *testing.T) {
+	runtime := RuntimeManifest{Volumes: []string{"pretrained:/models:ro", "../../etc:/etc"}}
+	if err := runtime.ValidatePackageVolumes(); err == nil {
+		t.Error("ValidatePackageVolumes accepted a volume outside of the package folder")
+	}
+	runtime.Volumes = runtime.Volumes[:1]
+	if err := runtime.ValidatePackageVolumes(); err != nil {
+		t.Errorf("ValidatePackageVolumes = %v", err)
+	}
+}
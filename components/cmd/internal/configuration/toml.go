@@ -1,15 +1,25 @@
 package configuration
 
 import (
-	ent "github.com/autoai-org/aid/ent/generated"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/autoai-org/aid/internal/utilities"
 )
 
+// PretrainedFile lists the pretrained models of a package
+const PretrainedFile = "pretrained.toml"
+
 // Pretrained defines the basic structure of pretrained file,
 // it do not need to be stored in database
 // and therefore has no `db` bindings.
 type Pretrained struct {
 	Name string `toml:"name"`
 	URL  string `toml:"url"`
+	// Sha256 and Size are optional, they are verified after the download
+	Sha256 string `toml:"sha256"`
+	Size   int64  `toml:"size"`
 }
 
 // Pretraineds is the collection/list of pretrained files
@@ -18,9 +28,33 @@ type Pretraineds struct {
 	Models []Pretrained `toml:"models"`
 }
 
-// PackageConfig is the toml interface as in aid.toml
-type PackageConfig struct {
-	// Solvers is the declaration of all solvers
-	Solvers []ent.Solver   `toml:"solvers"`
-	Package ent.Repository `toml:"package"`
+// Validate returns an error listing the invalid models, their sha256 is used
+// in file paths so it has to be checked before the models are downloaded
+func (pretraineds Pretraineds) Validate() error {
+	var messages []string
+	for _, issue := range pretraineds.issues(nil) {
+		messages = append(messages, issue.String())
+	}
+	if len(messages) > 0 {
+		return errors.New(strings.Join(messages, "\n"))
+	}
+	return nil
+}
+
+// issues reports the invalid models, lines maps keys like models.0.sha256 to their line
+func (pretraineds Pretraineds) issues(lines map[string]int) []ManifestIssue {
+	var issues []ManifestIssue
+	for idx, model := range pretraineds.Models {
+		key := fmt.Sprintf("models.%d", idx)
+		if model.URL == "" {
+			issues = append(issues, ManifestIssue{File: PretrainedFile, Line: lines[key], Field: fmt.Sprintf("models[%d].url", idx), Message: "is required"})
+		}
+		if model.Sha256 == "" {
+			continue
+		}
+		if _, err := utilities.NormalizeSha256(model.Sha256); err != nil {
+			issues = append(issues, ManifestIssue{File: PretrainedFile, Line: lines[key+".sha256"], Field: fmt.Sprintf("models[%d].sha256", idx), Message: err.Error()})
+		}
+	}
+	return issues
 }
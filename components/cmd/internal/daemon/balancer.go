@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package daemon
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	"github.com/autoai-org/aid/internal/runtime/requests"
+	"github.com/autoai-org/aid/internal/system"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+const (
+	// RoundRobin sends the requests of a solver to its replicas in turn
+	RoundRobin = "round-robin"
+	// LeastConnections sends a request to the replica with the fewest requests in flight
+	LeastConnections = "least-connections"
+)
+
+// ejectionPeriod is how long a replica that cannot be reached gets no requests
+const ejectionPeriod = 30 * time.Second
+
+// balancer spreads the requests of the gateway over the replicas of a solver.
+// Unhealthy replicas and replicas that recently could not be reached are skipped,
+// replicas whose health is not known yet are only used if none is healthy.
+type balancer struct {
+	strategy string
+	mutex    sync.Mutex
+	// next is the round-robin position of each solver
+	next map[string]int
+	// active is the number of requests in flight of each container
+	active map[string]int
+	// ejected maps containers to the time they get requests again
+	ejected map[string]time.Time
+}
+
+var (
+	defaultBalancer     *balancer
+	defaultBalancerOnce sync.Once
+)
+
+// newDefaultBalancer returns the balancer of the gateway, the strategy is
+// LoadBalancing of the system config, round-robin by default
+func newDefaultBalancer() *balancer {
+	defaultBalancerOnce.Do(func() {
+		strategy := system.NewDefaultConfig().LoadBalancing
+		if strategy != LeastConnections {
+			if strategy != "" && strategy != RoundRobin {
+				utilities.Formatter.Warn("Unknown load balancing " + strategy + ", using " + RoundRobin)
+			}
+			strategy = RoundRobin
+		}
+		defaultBalancer = newBalancer(strategy)
+	})
+	return defaultBalancer
+}
+
+// newBalancer returns an empty balancer with the given strategy
+func newBalancer(strategy string) *balancer {
+	return &balancer{
+		strategy: strategy,
+		next:     make(map[string]int),
+		active:   make(map[string]int),
+		ejected:  make(map[string]time.Time),
+	}
+}
+
+// pick returns the replica to send the request to, nil if there is none.
+// The caller must call release once the request is done.
+func (b *balancer) pick(solver string, containers []*ent.Container) *ent.Container {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var healthy, unknown []*ent.Container
+	for _, container := range containers {
+		if until, ok := b.ejected[container.UID]; ok {
+			if time.Now().Before(until) {
+				continue
+			}
+			delete(b.ejected, container.UID)
+		}
+		switch container.Health {
+		case requests.HealthHealthy:
+			healthy = append(healthy, container)
+		case requests.HealthUnhealthy:
+		default:
+			unknown = append(unknown, container)
+		}
+	}
+	candidates := healthy
+	if len(candidates) == 0 {
+		candidates = unknown
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	// a stable order, so that round-robin visits every replica
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].UID < candidates[j].UID })
+	position := b.next[solver]
+	b.next[solver] = position + 1
+	picked := candidates[position%len(candidates)]
+	if b.strategy == LeastConnections {
+		// ties are broken in round-robin order
+		for offset := range candidates {
+			candidate := candidates[(position+offset)%len(candidates)]
+			if b.active[candidate.UID] < b.active[picked.UID] {
+				picked = candidate
+			}
+		}
+	}
+	b.active[picked.UID]++
+	return picked
+}
+
+// release marks a request to the container as done
+func (b *balancer) release(containerUID string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.active[containerUID]--; b.active[containerUID] <= 0 {
+		delete(b.active, containerUID)
+	}
+}
+
+// eject stops sending requests to the container for ejectionPeriod
+func (b *balancer) eject(containerUID string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.ejected[containerUID] = time.Now().Add(ejectionPeriod)
+}
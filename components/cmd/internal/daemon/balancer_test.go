@@ -0,0 +1,113 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	"github.com/autoai-org/aid/internal/runtime/requests"
+)
+
+func replicas(healths ...string) []*ent.Container {
+	containers := make([]*ent.Container, len(healths))
+	for i, health := range healths {
+		containers[i] = &ent.Container{UID: string(rune('a' + i)), Health: health}
+	}
+	return containers
+}
+
+// picks returns the UIDs of n consecutive picks, releasing each one if release is set
+func picks(b *balancer, containers []*ent.Container, n int, release bool) string {
+	var uids string
+	for i := 0; i < n; i++ {
+		picked := b.pick("solver", containers)
+		if picked == nil {
+			uids += "-"
+			continue
+		}
+		uids += picked.UID
+		if release {
+			b.release(picked.UID)
+		}
+	}
+	return uids
+}
+
+func TestBalancerRoundRobin(t *testing.T) {
+	healthy, unhealthy, starting := requests.HealthHealthy, requests.HealthUnhealthy, requests.HealthStarting
+	tests := []struct {
+		name    string
+		healths []string
+		want    string
+	}{
+		{name: "all healthy", healths: []string{healthy, healthy, healthy}, want: "abcabc"},
+		{name: "skips unhealthy", healths: []string{healthy, unhealthy, healthy}, want: "acacac"},
+		{name: "prefers healthy over unknown", healths: []string{starting, healthy, ""}, want: "bbbbbb"},
+		{name: "falls back to unknown", healths: []string{starting, unhealthy, ""}, want: "acacac"},
+		{name: "none available", healths: []string{unhealthy, unhealthy}, want: "------"},
+		{name: "no replicas", want: "------"},
+	}
+	for _, test := range tests {
+		b := newBalancer(RoundRobin)
+		if got := picks(b, replicas(test.healths...), 6, true); got != test.want {
+			t.Errorf("%s: picked %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestBalancerRoundRobinStableOrder(t *testing.T) {
+	b := newBalancer(RoundRobin)
+	containers := replicas(requests.HealthHealthy, requests.HealthHealthy, requests.HealthHealthy)
+	reversed := []*ent.Container{containers[2], containers[1], containers[0]}
+	if got := picks(b, containers, 2, true) + picks(b, reversed, 4, true); got != "abcabc" {
+		t.Errorf("picked %q, want %q", got, "abcabc")
+	}
+}
+
+func TestBalancerLeastConnections(t *testing.T) {
+	b := newBalancer(LeastConnections)
+	containers := replicas(requests.HealthHealthy, requests.HealthHealthy, requests.HealthUnhealthy)
+	// without releases the requests are spread evenly over the healthy replicas
+	if got := picks(b, containers, 4, false); got != "abab" {
+		t.Errorf("picked %q, want %q", got, "abab")
+	}
+	// b finishes its requests, so it gets the next ones until it is as busy as a
+	b.release("b")
+	b.release("b")
+	if got := picks(b, containers, 3, false); got != "bba" {
+		t.Errorf("picked %q, want %q", got, "bba")
+	}
+	if b.active["a"] != 3 || b.active["b"] != 2 || b.active["c"] != 0 {
+		t.Errorf("active = %v, want a:3 b:2", b.active)
+	}
+}
+
+func TestBalancerRelease(t *testing.T) {
+	b := newBalancer(LeastConnections)
+	b.release("a")
+	if _, ok := b.active["a"]; ok {
+		t.Errorf("releasing an idle container left active = %v", b.active)
+	}
+}
+
+func TestBalancerEject(t *testing.T) {
+	b := newBalancer(RoundRobin)
+	containers := replicas(requests.HealthHealthy, requests.HealthHealthy)
+	b.eject("a")
+	if got := picks(b, containers, 3, true); got != "bbb" {
+		t.Errorf("picked %q while a is ejected, want %q", got, "bbb")
+	}
+	// once the ejection period is over, a gets requests again
+	b.ejected["a"] = time.Now().Add(-time.Second)
+	if got := picks(b, containers, 2, true); got != "ab" && got != "ba" {
+		t.Errorf("picked %q after the ejection period, want both replicas", got)
+	}
+	if _, ok := b.ejected["a"]; ok {
+		t.Error("an expired ejection was not removed")
+	}
+}
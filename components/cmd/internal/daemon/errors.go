@@ -0,0 +1,39 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package daemon
+
+import (
+	"net/http"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	"github.com/autoai-org/aid/internal/utilities"
+	"github.com/gin-gonic/gin"
+)
+
+// httpStatus maps the category of err to a http status code
+func httpStatus(err error) int {
+	if ent.IsNotFound(err) {
+		return http.StatusNotFound
+	}
+	switch utilities.Category(err) {
+	case utilities.RequestError:
+		return http.StatusBadRequest
+	case utilities.PackageError:
+		return http.StatusUnprocessableEntity
+	case utilities.DockerError, utilities.NetworkError:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// abortWithError writes the error as a json response
+func abortWithError(c *gin.Context, err error) {
+	c.AbortWithStatusJSON(httpStatus(err), gin.H{
+		"error": err.Error(),
+		"code":  int(utilities.Category(err)),
+	})
+}
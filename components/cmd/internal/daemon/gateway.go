@@ -0,0 +1,121 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package daemon
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	"github.com/autoai-org/aid/internal/runtime/docker"
+	"github.com/autoai-org/aid/internal/runtime/requests"
+	"github.com/autoai-org/aid/internal/utilities"
+	"github.com/gin-gonic/gin"
+)
+
+// gatewayEndpoints are the endpoints of the solver servers exposed by the
+// gateway as /[endpoint]/[vendor]/[package]/[solver]
+var gatewayEndpoints = []string{"infer", "batch", "train"}
+
+// isGatewayPath returns true if the path is an endpoint of a solver
+func isGatewayPath(path string) bool {
+	for _, endpoint := range gatewayEndpoints {
+		if strings.HasPrefix(path, "/"+endpoint+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// proxySolver forwards the request to a running container of the solver,
+// chosen by the balancer, so that clients do not need to know the host
+// ports of the containers
+func proxySolver(endpoint string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodOptions {
+			// answered by beforeResponse
+			return
+		}
+		solver := c.Param("vendor") + "/" + c.Param("package") + "/" + c.Param("solver")
+		containers, err := docker.RunningContainers(c.Param("vendor"), c.Param("package"), c.Param("solver"))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		balancer := newDefaultBalancer()
+		container := balancer.pick(solver, containers)
+		if container == nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": "no running container for " + solver + ", start one with aid scale " + solver,
+				"code":  int(utilities.DockerError),
+			})
+			return
+		}
+		defer balancer.release(container.UID)
+		proxyContainer(c, solver, container, "/"+endpoint)
+	}
+}
+
+// proxyStatic forwards /static/[vendor]/[package]/[solver]/[filename] to the
+// container that wrote the file, e.g. the result of a batch request. The
+// container is given by the aid-container header or the container query
+// parameter, which are set to the aid-container header of the response that
+// returned the filename. It may only be left out if the solver has one replica.
+func proxyStatic(c *gin.Context) {
+	solver := c.Param("vendor") + "/" + c.Param("package") + "/" + c.Param("solver")
+	containers, err := docker.RunningContainers(c.Param("vendor"), c.Param("package"), c.Param("solver"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	uid := c.GetHeader("aid-container")
+	if uid == "" {
+		uid = c.Query("container")
+	}
+	if uid == "" && len(containers) == 1 {
+		uid = containers[0].UID
+	}
+	var container *ent.Container
+	for _, candidate := range containers {
+		if candidate.UID == uid {
+			container = candidate
+		}
+	}
+	if container == nil {
+		message := "container " + uid + " of " + solver + " is not running"
+		if uid == "" {
+			message = solver + " has " + strconv.Itoa(len(containers)) + " running containers, set the aid-container header to the one that wrote the file"
+		}
+		abortWithError(c, utilities.NewError(utilities.RequestError, message, nil))
+		return
+	}
+	proxyContainer(c, solver, container, "/static"+c.Param("filepath"))
+}
+
+// proxyContainer forwards the request to path on the container of the solver
+func proxyContainer(c *gin.Context, solver string, container *ent.Container, path string) {
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: requests.SolverAddress(container)})
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		// the cors headers are set by beforeResponse
+		for key := range resp.Header {
+			if strings.HasPrefix(key, "Access-Control-") {
+				resp.Header.Del(key)
+			}
+		}
+		return nil
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		newDefaultBalancer().eject(container.UID)
+		abortWithError(c, utilities.NewError(utilities.NetworkError, "cannot reach container "+container.UID+" of "+solver, err))
+	}
+	c.Request.URL.Path = path
+	c.Request.URL.RawPath = ""
+	c.Header("aid-container", container.UID)
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
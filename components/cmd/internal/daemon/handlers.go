@@ -0,0 +1,289 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	entContainer "github.com/autoai-org/aid/ent/generated/container"
+	entImage "github.com/autoai-org/aid/ent/generated/image"
+	entRepository "github.com/autoai-org/aid/ent/generated/repository"
+	entSolver "github.com/autoai-org/aid/ent/generated/solver"
+	"github.com/autoai-org/aid/internal/configuration"
+	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/jobs"
+	"github.com/autoai-org/aid/internal/runtime/cargo"
+	"github.com/autoai-org/aid/internal/runtime/docker"
+	"github.com/autoai-org/aid/internal/utilities"
+	"github.com/autoai-org/aid/internal/workflow"
+	"github.com/gin-gonic/gin"
+)
+
+// installPackageRequest is the body of POST /api/v1/packages
+type installPackageRequest struct {
+	RemoteURL string `json:"remote_url" binding:"required"`
+}
+
+// buildImageRequest is the body of POST /api/v1/images
+type buildImageRequest struct {
+	Vendor  string `json:"vendor" binding:"required"`
+	Package string `json:"package" binding:"required"`
+	Solver  string `json:"solver" binding:"required"`
+}
+
+// createContainerRequest is the body of POST /api/v1/containers
+type createContainerRequest struct {
+	Image string `json:"image" binding:"required"`
+	// Port is the host port, a free one is allocated if it is empty
+	Port string `json:"port"`
+	Name string `json:"name"`
+	// the runtime settings override the ones in aid.toml of the package
+	configuration.RuntimeManifest
+}
+
+func getPackages(c *gin.Context) {
+	repos, err := database.NewDefaultDB().Repository.Query().WithSolvers().All(context.Background())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, repos)
+}
+
+func getPackage(c *gin.Context) {
+	repo, err := database.NewDefaultDB().Repository.Query().Where(entRepository.UID(c.Param("uid"))).WithSolvers().First(context.Background())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, repo)
+}
+
+func installPackage(c *gin.Context) {
+	var request installPackageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, utilities.NewError(utilities.RequestError, "invalid request", err))
+		return
+	}
+	job, err := jobPool.Submit(jobs.KindInstall, request.RemoteURL)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+func removePackage(c *gin.Context) {
+	if err := cargo.RemovePackage(c.Param("uid")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func getSolvers(c *gin.Context) {
+	solvers, err := database.NewDefaultDB().Solver.Query().WithRepository().WithImage().All(context.Background())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, solvers)
+}
+
+func getSolver(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, utilities.NewError(utilities.RequestError, "invalid request", err))
+		return
+	}
+	solver, err := database.NewDefaultDB().Solver.Query().WithRepository().WithImage().Where(entSolver.ID(id)).First(context.Background())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, solver)
+}
+
+func getImages(c *gin.Context) {
+	images, err := database.NewDefaultDB().Image.Query().WithSolver().All(context.Background())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, images)
+}
+
+func getImage(c *gin.Context) {
+	image, err := database.NewDefaultDB().Image.Query().Where(entImage.UID(c.Param("uid"))).WithSolver().First(context.Background())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, image)
+}
+
+func buildImage(c *gin.Context) {
+	var request buildImageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, utilities.NewError(utilities.RequestError, "invalid request", err))
+		return
+	}
+	job, err := jobPool.Submit(jobs.KindBuild, request.Vendor+"/"+request.Package+"/"+request.Solver)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+func removeImage(c *gin.Context) {
+	if err := docker.RemoveImage(c.Param("uid")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func getContainers(c *gin.Context) {
+	containers, err := database.NewDefaultDB().Container.Query().WithImage().All(context.Background())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, containers)
+}
+
+func getContainer(c *gin.Context) {
+	container, err := database.NewDefaultDB().Container.Query().Where(entContainer.UID(c.Param("uid"))).WithImage().First(context.Background())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, container)
+}
+
+func createContainer(c *gin.Context) {
+	var request createContainerRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, utilities.NewError(utilities.RequestError, "invalid request", err))
+		return
+	}
+	if err := request.RuntimeManifest.ValidatePackageVolumes(); err != nil {
+		abortWithError(c, utilities.NewError(utilities.RequestError, "invalid container settings", err))
+		return
+	}
+	created, err := workflow.CreateContainer(request.Image, request.Port, request.Name, request.RuntimeManifest)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	container, err := database.NewDefaultDB().Container.Query().Where(entContainer.ID(created.ID)).WithImage().First(context.Background())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, container)
+}
+
+func startContainer(c *gin.Context) {
+	var timeout time.Duration
+	if wait := c.Query("wait"); wait != "" {
+		var err error
+		if timeout, err = time.ParseDuration(wait); err != nil {
+			abortWithError(c, utilities.NewError(utilities.RequestError, "invalid wait "+wait+", expected a duration", err))
+			return
+		}
+	}
+	if err := docker.Start(c.Param("uid")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if timeout > 0 {
+		if err := docker.WaitHealthy(c.Param("uid"), timeout); err != nil {
+			abortWithError(c, err)
+			return
+		}
+	}
+	getContainer(c)
+}
+
+func stopContainer(c *gin.Context) {
+	if err := docker.Stop(c.Param("uid")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	getContainer(c)
+}
+
+func removeContainer(c *gin.Context) {
+	if err := docker.RemoveContainer(c.Param("uid")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func getJobs(c *gin.Context) {
+	jobList, err := jobs.List()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, jobList)
+}
+
+func getJob(c *gin.Context) {
+	job, err := jobs.Get(c.Param("uid"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func cancelJob(c *gin.Context) {
+	job, err := jobPool.Cancel(c.Param("uid"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// getEvents returns the recorded docker events, the latest first. The events
+// can be filtered with ?type=container|image, ?actor=[Unique ID], ?action=die,
+// ?since=[RFC3339 time or duration, e.g. 1h] and limited with ?limit=100.
+func getEvents(c *gin.Context) {
+	filter := docker.EventFilter{
+		Type:   c.Query("type"),
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+	}
+	if since := c.Query("since"); since != "" {
+		if duration, err := time.ParseDuration(since); err == nil {
+			filter.Since = time.Now().Add(-duration)
+		} else if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			abortWithError(c, utilities.NewError(utilities.RequestError, "invalid since "+since+", expected a RFC3339 time or a duration", err))
+			return
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			abortWithError(c, utilities.NewError(utilities.RequestError, "invalid limit "+limit, err))
+			return
+		}
+	}
+	events, err := docker.ListEvents(filter)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package daemon
+
+import (
+	"context"
+	"time"
+
+	entContainer "github.com/autoai-org/aid/ent/generated/container"
+	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/runtime/docker"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// healthCheckInterval is how often the health of the running containers is checked
+const healthCheckInterval = 10 * time.Second
+
+// monitorHealth keeps the health of the running containers up to date, so
+// that the gateway skips unhealthy replicas. Images built before the
+// HEALTHCHECK was added to the dockerfile are only checked this way.
+func monitorHealth() {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		containers, err := database.NewDefaultDB().Container.Query().Where(entContainer.Running(true)).All(context.Background())
+		if err != nil {
+			utilities.Formatter.Warn("Cannot fetch running containers: " + err.Error())
+			continue
+		}
+		for _, container := range containers {
+			// stopped containers are handled by the events and the reconciler
+			docker.CheckHealth(context.Background(), container)
+		}
+	}
+}
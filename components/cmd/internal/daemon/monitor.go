@@ -222,8 +222,11 @@ func (p *Prometheus) runServer() {
 }
 
 func (p *Prometheus) getMetrics() []byte {
-	response, _ := http.Get(p.Ppg.MetricsURL)
-
+	response, err := http.Get(p.Ppg.MetricsURL)
+	if err != nil {
+		log.WithError(err).Errorln("Error fetching metrics")
+		return nil
+	}
 	defer response.Body.Close()
 	body, _ := ioutil.ReadAll(response.Body)
 
@@ -440,7 +443,7 @@ func queryJSON(metricString string) ([]*prom2json.Family, error) {
 	mfChan := make(chan *dto.MetricFamily, 1024)
 	err := prom2json.ParseReader(strings.NewReader(metricString), mfChan)
 	if err != nil {
-		utilities.ReportError(err, "Cannot retrieve metrics from source.")
+		return nil, utilities.NewError(utilities.UnknownError, "cannot retrieve metrics from source", err)
 	}
 	result := []*prom2json.Family{}
 	for mf := range mfChan {
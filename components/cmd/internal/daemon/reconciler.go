@@ -0,0 +1,41 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package daemon
+
+import (
+	"time"
+
+	"github.com/autoai-org/aid/internal/runtime/docker"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// reconcileInterval is how often the database is synced with docker
+const reconcileInterval = time.Minute
+
+// reconcile syncs the database with docker at startup and then periodically.
+// Drifts that cannot be fixed are only reported once.
+func reconcile() {
+	reported := make(map[string]bool)
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		drifts, err := docker.Reconcile(true)
+		if err != nil {
+			utilities.Formatter.Warn("Cannot reconcile the database with docker: " + err.Error())
+			continue
+		}
+		for _, drift := range drifts {
+			key := drift.Entity + " " + drift.UID + " " + drift.Kind
+			switch {
+			case drift.Fixed:
+				utilities.Formatter.Info("Reconciled " + drift.Entity + " " + drift.UID + ": " + drift.Message)
+			case !reported[key]:
+				reported[key] = true
+				utilities.Formatter.Warn("Cannot reconcile " + drift.Entity + " " + drift.UID + ": " + drift.Message)
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"github.com/autoai-org/aid/internal/runtime/git"
+	"github.com/autoai-org/aid/internal/utilities"
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,5 +19,44 @@ func getRouter() *gin.Engine {
 	r.Use(beforeResponse())
 	r.Use(gin.Recovery())
 	r.Any("/git", gin.WrapH(gitService))
+	// packages published to the local registry can be installed by other
+	// nodes with $AID_REGISTRY set to http://[host]:[port]/registry
+	r.Static("/registry", utilities.GetFolder(utilities.REGISTRYFOLDER))
+	// the solvers are reachable at stable addresses however their containers are recreated
+	for _, endpoint := range gatewayEndpoints {
+		r.Any("/"+endpoint+"/:vendor/:package/:solver", proxySolver(endpoint))
+	}
+	// files written by the solvers, e.g. the results of batch requests
+	r.GET("/static/:vendor/:package/:solver/*filepath", proxyStatic)
+	v1 := r.Group("/api/v1")
+	{
+		v1.GET("/packages", getPackages)
+		v1.GET("/packages/:uid", getPackage)
+		v1.POST("/packages", installPackage)
+		v1.DELETE("/packages/:uid", removePackage)
+
+		v1.GET("/solvers", getSolvers)
+		v1.GET("/solvers/:id", getSolver)
+
+		v1.GET("/images", getImages)
+		v1.GET("/images/:uid", getImage)
+		v1.POST("/images", buildImage)
+		v1.DELETE("/images/:uid", removeImage)
+
+		v1.GET("/containers", getContainers)
+		v1.GET("/containers/:uid", getContainer)
+		v1.POST("/containers", createContainer)
+		v1.PUT("/containers/:uid/start", startContainer)
+		v1.PUT("/containers/:uid/stop", stopContainer)
+		v1.DELETE("/containers/:uid", removeContainer)
+
+		v1.GET("/jobs", getJobs)
+		v1.GET("/jobs/:uid", getJob)
+		v1.DELETE("/jobs/:uid", cancelJob)
+
+		v1.GET("/logs/:id", streamLogs)
+		v1.GET("/downloads", streamDownloads)
+		v1.GET("/events", getEvents)
+	}
 	return r
 }
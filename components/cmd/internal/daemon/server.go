@@ -1,20 +1,52 @@
 package daemon
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/autoai-org/aid/internal/jobs"
+	"github.com/autoai-org/aid/internal/runtime/docker"
+	"github.com/autoai-org/aid/internal/system"
 	"github.com/autoai-org/aid/internal/utilities"
 	"github.com/gin-gonic/gin"
 )
 
-// beforeResponse set global header to enable cors and set response header
+// defaultWorkers is the number of jobs that can be processed at the same time
+const defaultWorkers = 2
+
+// jobPool processes long-running operations, e.g. builds and installs
+var jobPool *jobs.Pool
+
+// mutatingMethods change the state of aid, browsers send some of them
+// cross-origin without asking the server first
+var mutatingMethods = map[string]bool{
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// beforeResponse set global header to enable cors and set response header.
+// Web pages may call the gateway and read the api, but only the origins in
+// AllowedOrigins of the config may change aid, e.g. create containers.
 func beforeResponse() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.GetHeader("Origin")
 		c.Writer.Header().Set("aid-version", "1.0.1 @ dev")
+		method := c.Request.Method
+		if method == http.MethodOptions {
+			method = c.GetHeader("Access-Control-Request-Method")
+		}
+		if origin != "" && mutatingMethods[method] && !isGatewayPath(c.Request.URL.Path) && !allowedOrigin(origin) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "requests from " + origin + " cannot change aid, add it to AllowedOrigins in config.toml to allow them",
+				"code":  int(utilities.RequestError),
+			})
+			return
+		}
 		if c.Writer.Header().Get("Access-Control-Allow-Origin") == "" {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 		}
@@ -27,6 +59,15 @@ func beforeResponse() gin.HandlerFunc {
 	}
 }
 
+func allowedOrigin(origin string) bool {
+	for _, allowed := range system.NewDefaultConfig().AllowedOrigins {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
 // RunServer starts the http(s) service
 func RunServer(port string) {
 	if port == "" {
@@ -34,10 +75,15 @@ func RunServer(port string) {
 		utilities.Formatter.Warn("Port not specified, using the default " + port)
 	}
 	utilities.Formatter.Info("Starting the server...")
+	jobPool = jobs.NewPool(defaultWorkers)
+	utilities.ReportError(jobPool.Start(), "Cannot start job workers")
+	go reconcile()
+	go docker.WatchEvents(context.Background())
+	go monitorHealth()
 	r := getRouter()
 	err := r.Run("127.0.0.1:" + port)
 	utilities.ReportError(err, "Cannot start server")
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	utilities.Formatter.Info("Server is shutting down gracefully...")
@@ -0,0 +1,75 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package daemon
+
+import (
+	"net/http"
+
+	"github.com/autoai-org/aid/internal/runtime/cargo"
+	"github.com/autoai-org/aid/internal/workflow"
+	"github.com/gin-gonic/gin"
+)
+
+// sseWriter sends everything written to it as server-sent events
+type sseWriter struct {
+	c     *gin.Context
+	event string
+}
+
+func (w sseWriter) Write(p []byte) (int, error) {
+	w.c.SSEvent(w.event, string(p))
+	w.c.Writer.Flush()
+	return len(p), nil
+}
+
+// streamLogs sends the logs of a container, build, job or the system
+// as server-sent events. Use ?follow=true to keep the stream open.
+func streamLogs(c *gin.Context) {
+	follow := c.Query("follow") == "true"
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	err := workflow.StreamLogs(c.Request.Context(), c.Param("id"), follow, sseWriter{c: c, event: "log"})
+	if err != nil {
+		c.SSEvent("error", err.Error())
+		return
+	}
+	c.SSEvent("end", c.Param("id"))
+}
+
+// streamDownloads sends the progress of all pretrained model downloads as
+// server-sent events until the client disconnects. Use ?package=[vendor]/[name]
+// to only receive the downloads of a single package.
+func streamDownloads(c *gin.Context) {
+	packageName := c.Query("package")
+	// events are dropped if the client cannot keep up
+	events := make(chan cargo.DownloadEvent, 256)
+	unsubscribe := cargo.SubscribeDownloads(func(event cargo.DownloadEvent) {
+		if packageName != "" && event.Package != packageName {
+			return
+		}
+		select {
+		case events <- event:
+		default:
+		}
+	})
+	defer unsubscribe()
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event := <-events:
+			c.SSEvent("progress", event)
+			c.Writer.Flush()
+		}
+	}
+}
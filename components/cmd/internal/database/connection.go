@@ -2,10 +2,12 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"path/filepath"
 
 	ent "github.com/autoai-org/aid/ent/generated"
 	"github.com/autoai-org/aid/internal/utilities"
+	entsql "github.com/facebook/ent/dialect/sql"
 
 	// import sqlite3
 	_ "github.com/mattn/go-sqlite3"
@@ -14,15 +16,19 @@ import (
 // DefaultDB is the instance shared by all modules
 var DefaultDB *ent.Client
 
+// defaultSQL is the connection below DefaultDB, used by migrations
+var defaultSQL *sql.DB
+
 // NewDefaultDB returns the database client
 func NewDefaultDB() *ent.Client {
 	if DefaultDB != nil {
 		return DefaultDB
 	}
-	client, err := ent.Open("sqlite3", filepath.Join(utilities.GetBasePath(), "aid.db?_fk=1"))
-	DefaultDB = client
+	driver, err := entsql.Open("sqlite3", filepath.Join(utilities.GetBasePath(), "aid.db?_fk=1&_busy_timeout=5000&_txlock=immediate"))
 	utilities.ReportError(err, "cannot open database")
-	if err := client.Schema.Create(context.Background()); err != nil {
+	DefaultDB = ent.NewClient(ent.Driver(driver))
+	defaultSQL = driver.DB()
+	if err := Migrate(context.Background()); err != nil {
 		utilities.ReportError(err, "Failed updating database schema")
 	}
 	return DefaultDB
@@ -0,0 +1,48 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Migrate creates or updates the tables of the default database and moves the
+// data of edges whose foreign key has moved to another table
+func Migrate(ctx context.Context) error {
+	client := NewDefaultDB()
+	if err := client.Schema.Create(ctx); err != nil {
+		return err
+	}
+	return backfillContainerImages(ctx, defaultSQL)
+}
+
+// backfillContainerImages copies the image of each container from
+// images.container_image, which was used before a container had a single
+// image, into containers.container_image. The old column is cleared so that
+// the copy only happens once.
+func backfillContainerImages(ctx context.Context, db *sql.DB) error {
+	var legacy int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM pragma_table_info('images') WHERE name = 'container_image'").Scan(&legacy)
+	if err != nil || legacy == 0 {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE containers SET container_image =
+		(SELECT MIN(images.id) FROM images WHERE images.container_image = containers.id)
+		WHERE container_image IS NULL`)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, "UPDATE images SET container_image = NULL")
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package jobs
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	entJob "github.com/autoai-org/aid/ent/generated/job"
+	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/jobs/state"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+const (
+	// KindBuild builds a docker image, the target is vendor/package/solver
+	KindBuild = "build"
+	// KindInstall installs a package, the target is the remote address
+	KindInstall = "install"
+)
+
+// Create saves a new queued job into the database
+func Create(kind string, target string) (*ent.Job, error) {
+	uid := utilities.GenerateUUIDv4()
+	logPath := filepath.Join(utilities.GetBasePath(), "logs", "jobs", uid)
+	return database.NewDefaultDB().Job.Create().
+		SetUID(uid).
+		SetKind(kind).
+		SetTarget(target).
+		SetState(state.Queued).
+		SetLogpath(logPath).
+		Save(context.Background())
+}
+
+// Get returns the job with the given unique id
+func Get(uid string) (*ent.Job, error) {
+	return database.NewDefaultDB().Job.Query().Where(entJob.UID(uid)).First(context.Background())
+}
+
+// List returns all jobs, the latest first
+func List() ([]*ent.Job, error) {
+	return database.NewDefaultDB().Job.Query().Order(ent.Desc(entJob.FieldCreatedAt)).All(context.Background())
+}
+
+// Cancel marks the job as cancelled in the database. Running jobs will
+// be interrupted by the worker pool that is processing them.
+func Cancel(uid string) (*ent.Job, error) {
+	job, err := Get(uid)
+	if err != nil {
+		return nil, err
+	}
+	if state.IsFinished(job.State) {
+		return job, utilities.NewError(utilities.RequestError, "job "+uid+" has already "+job.State, nil)
+	}
+	// the state is only changed if it is still the one that was read, a
+	// queued job may be picked up by a worker in the meantime
+	update := database.NewDefaultDB().Job.Update().
+		Where(entJob.ID(job.ID), entJob.State(job.State)).
+		SetState(state.Cancelled)
+	if job.State == state.Queued {
+		update = update.SetFinishedAt(time.Now())
+	}
+	updated, err := update.Save(context.Background())
+	if err != nil {
+		return job, utilities.NewError(utilities.DatabaseError, "cannot cancel job "+uid, err)
+	}
+	if updated == 0 {
+		return Cancel(uid)
+	}
+	return Get(uid)
+}
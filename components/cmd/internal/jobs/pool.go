@@ -0,0 +1,275 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package jobs
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	entJob "github.com/autoai-org/aid/ent/generated/job"
+	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/jobs/state"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// watchInterval is how often the pool checks if running jobs have been
+// cancelled from another process, e.g. by ```aid jobs cancel```, and picks
+// up queued jobs that are not in the queue yet
+const watchInterval = 2 * time.Second
+
+// Runner performs the actual work of a job. It should return as soon as
+// possible once ctx is cancelled.
+type Runner func(ctx context.Context, job *ent.Job) error
+
+// Pool runs queued jobs with a fixed number of workers
+type Pool struct {
+	workers int
+	queue   chan int
+	runners map[string]Runner
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+	// pending holds the ids of the jobs in the queue
+	pending map[int]bool
+}
+
+// NewPool returns a pool with the default runners registered
+func NewPool(workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	pool := &Pool{
+		workers: workers,
+		queue:   make(chan int, 128),
+		runners: make(map[string]Runner),
+		cancels: make(map[string]context.CancelFunc),
+		pending: make(map[int]bool),
+	}
+	pool.Register(KindBuild, runBuild)
+	pool.Register(KindInstall, runInstall)
+	return pool
+}
+
+// Register sets the runner for the given kind of jobs
+func (p *Pool) Register(kind string, runner Runner) {
+	p.runners[kind] = runner
+}
+
+// Start recovers unfinished jobs and starts the workers
+func (p *Pool) Start() error {
+	client := database.NewDefaultDB()
+	// jobs that were running when the daemon stopped cannot be resumed
+	_, err := client.Job.Update().
+		Where(entJob.State(state.Running)).
+		SetState(state.Failed).
+		SetError("interrupted by daemon shutdown").
+		SetFinishedAt(time.Now()).
+		Save(context.Background())
+	if err != nil {
+		return err
+	}
+	for i := 0; i < p.workers; i++ {
+		go p.work()
+	}
+	if err := p.dispatch(); err != nil {
+		return err
+	}
+	go p.watch()
+	return nil
+}
+
+// Submit creates a new job and puts it into the queue. It does not block if
+// the queue is full, the job stays queued in the database and is picked up
+// by the pool once there is room.
+func (p *Pool) Submit(kind string, target string) (*ent.Job, error) {
+	if _, ok := p.runners[kind]; !ok {
+		return nil, utilities.NewError(utilities.RequestError, "unsupported job kind: "+kind, nil)
+	}
+	job, err := Create(kind, target)
+	if err != nil {
+		return nil, err
+	}
+	p.enqueue(job.ID)
+	return job, nil
+}
+
+// enqueue puts the job into the queue unless it is full or the job is
+// already in it, it returns false if the queue is full
+func (p *Pool) enqueue(id int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending[id] {
+		return true
+	}
+	select {
+	case p.queue <- id:
+		p.pending[id] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// dispatch puts the queued jobs of the database into the queue, the oldest
+// first. These are jobs submitted while the queue was full or by another
+// process, e.g. aid build --detach.
+func (p *Pool) dispatch() error {
+	queued, err := database.NewDefaultDB().Job.Query().
+		Where(entJob.State(state.Queued)).
+		Order(ent.Asc(entJob.FieldCreatedAt)).
+		All(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, job := range queued {
+		if !p.enqueue(job.ID) {
+			break
+		}
+	}
+	return nil
+}
+
+// Cancel cancels a queued or running job
+func (p *Pool) Cancel(uid string) (*ent.Job, error) {
+	job, err := Cancel(uid)
+	if err != nil {
+		return job, err
+	}
+	p.mu.Lock()
+	if cancel, ok := p.cancels[uid]; ok {
+		cancel()
+	}
+	p.mu.Unlock()
+	return job, nil
+}
+
+func (p *Pool) work() {
+	for id := range p.queue {
+		p.mu.Lock()
+		delete(p.pending, id)
+		p.mu.Unlock()
+		p.run(id)
+	}
+}
+
+func (p *Pool) run(id int) {
+	client := database.NewDefaultDB()
+	// the job only runs if it is still queued, e.g. it has not been cancelled
+	// since it was submitted
+	updated, err := client.Job.Update().
+		Where(entJob.ID(id), entJob.State(state.Queued)).
+		SetState(state.Running).
+		SetStartedAt(time.Now()).
+		Save(context.Background())
+	if err != nil {
+		utilities.Formatter.Error("Cannot start job " + strconv.Itoa(id) + ": " + err.Error())
+		return
+	}
+	if updated == 0 {
+		return
+	}
+	job, err := client.Job.Get(context.Background(), id)
+	if err != nil {
+		utilities.Formatter.Error("Cannot fetch job " + strconv.Itoa(id) + ": " + err.Error())
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.mu.Lock()
+	p.cancels[job.UID] = cancel
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.cancels, job.UID)
+		p.mu.Unlock()
+		cancel()
+	}()
+	// the job is only finished if it is still running, it may have been
+	// cancelled by another process in the meantime
+	update := client.Job.Update().Where(entJob.ID(job.ID), entJob.State(state.Running)).SetFinishedAt(time.Now())
+	jobLogger, err := utilities.NewLogger(job.Logpath)
+	if err != nil {
+		p.finish(job, update.SetState(state.Failed).SetError(err.Error()))
+		return
+	}
+	jobLogger.Info("Job " + job.UID + " started: " + job.Kind + " " + job.Target)
+	err = p.runners[job.Kind](ctx, job)
+	finalState := state.Succeeded
+	switch {
+	case ctx.Err() == context.Canceled:
+		finalState = state.Cancelled
+	case err != nil:
+		finalState = state.Failed
+		update = update.SetError(err.Error())
+	}
+	if !p.finish(job, update.SetState(finalState).SetFinishedAt(time.Now())) {
+		finalState = state.Cancelled
+	}
+	switch finalState {
+	case state.Cancelled:
+		jobLogger.Warn("Job " + job.UID + " cancelled")
+	case state.Failed:
+		jobLogger.Error("Job " + job.UID + " failed: " + err.Error())
+	default:
+		jobLogger.Info("Job " + job.UID + " succeeded")
+	}
+}
+
+// finish saves the final state of a running job. It returns false if the
+// job has been cancelled instead, its finish time is recorded then.
+func (p *Pool) finish(job *ent.Job, update *ent.JobUpdate) bool {
+	updated, err := update.Save(context.Background())
+	if err != nil {
+		utilities.Formatter.Error("Cannot update job " + job.UID + ": " + err.Error())
+		return true
+	}
+	if updated > 0 {
+		return true
+	}
+	_, err = database.NewDefaultDB().Job.Update().
+		Where(entJob.ID(job.ID), entJob.State(state.Cancelled), entJob.FinishedAtIsNil()).
+		SetFinishedAt(time.Now()).
+		Save(context.Background())
+	if err != nil {
+		utilities.Formatter.Error("Cannot update job " + job.UID + ": " + err.Error())
+	}
+	return false
+}
+
+// watch interrupts running jobs that have been cancelled in the database and
+// dispatches queued jobs
+func (p *Pool) watch() {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := p.dispatch(); err != nil {
+			utilities.Formatter.Warn("Cannot fetch queued jobs: " + err.Error())
+		}
+		p.mu.Lock()
+		uids := make([]string, 0, len(p.cancels))
+		for uid := range p.cancels {
+			uids = append(uids, uid)
+		}
+		p.mu.Unlock()
+		if len(uids) == 0 {
+			continue
+		}
+		cancelled, err := database.NewDefaultDB().Job.Query().
+			Where(entJob.UIDIn(uids...), entJob.State(state.Cancelled)).
+			All(context.Background())
+		if err != nil {
+			continue
+		}
+		p.mu.Lock()
+		for _, job := range cancelled {
+			if cancel, ok := p.cancels[job.UID]; ok {
+				cancel()
+			}
+		}
+		p.mu.Unlock()
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package jobs
+
+import (
+	"context"
+	"strings"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	"github.com/autoai-org/aid/internal/runtime/docker"
+	"github.com/autoai-org/aid/internal/utilities"
+	"github.com/autoai-org/aid/internal/workflow"
+)
+
+// runBuild builds the image of vendor/package/solver
+func runBuild(ctx context.Context, job *ent.Job) error {
+	buildInfo := strings.Split(job.Target, "/")
+	if len(buildInfo) != 3 {
+		return utilities.NewError(utilities.RequestError, "build target should be [vendor]/[package]/[solver], got "+job.Target, nil)
+	}
+	_, err := docker.BuildImageContext(ctx, buildInfo[0], buildInfo[1], buildInfo[2], job.Logpath)
+	return err
+}
+
+// runInstall installs the package from the remote address
+func runInstall(ctx context.Context, job *ent.Job) error {
+	_, err := workflow.PullPackageSourceContext(ctx, job.Target)
+	return err
+}
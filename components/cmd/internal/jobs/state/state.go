@@ -0,0 +1,26 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+// Package state holds the states of jobs, it has no dependencies so that
+// the workflows run by the jobs can check them too
+package state
+
+const (
+	// Queued means the job is waiting for a free worker
+	Queued = "queued"
+	// Running means the job is being processed by a worker
+	Running = "running"
+	// Succeeded means the job has finished without errors
+	Succeeded = "succeeded"
+	// Failed means the job has finished with an error
+	Failed = "failed"
+	// Cancelled means the job has been cancelled by the user
+	Cancelled = "cancelled"
+)
+
+// IsFinished returns true if a job in this state will not be processed anymore
+func IsFinished(state string) bool {
+	return state == Succeeded || state == Failed || state == Cancelled
+}
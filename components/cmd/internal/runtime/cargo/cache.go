@@ -0,0 +1,308 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package cargo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/autoai-org/aid/internal/system"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// The cache stores every artifact once, keyed by its digest, under
+// [cache]/sha256/[digest] with its metadata in [cache]/sha256/[digest].json.
+// Packages get hardlinks, or symlinks if hardlinks are not possible, so that
+// removing a package does not remove the artifacts it shares with others.
+const (
+	artifactsFolder = "sha256"
+	downloadsFolder = "downloads"
+	metaSuffix      = ".json"
+	// cacheLock is held shared while artifacts are cached and linked, and
+	// exclusively while they are pruned
+	cacheLock  = ".lock"
+	lockSuffix = ".lock"
+)
+
+// stagingUser is listed in UsedBy for links in packages being installed or upgraded
+const stagingUser = "(installing)"
+
+// CachedArtifact is an artifact in the cache
+type CachedArtifact struct {
+	Digest  string    `json:"digest"`
+	Name    string    `json:"name"`
+	URL     string    `json:"url"`
+	Size    int64     `json:"size"`
+	AddedAt time.Time `json:"added_at"`
+	// UsedBy lists the packages, as [vendor]/[name], that link to the artifact
+	UsedBy []string `json:"used_by"`
+}
+
+// CacheArtifact downloads the artifact into the cache and returns its digest.
+// Nothing is downloaded if an artifact with the expected digest, or from the
+// same url if no digest is given, is already cached. Interrupted downloads
+// are resumed by the next call with the same url or digest. The url is read
+// from the artifact source of its scheme, see system.ArtifactOpener.
+func CacheArtifact(ctx context.Context, url string, options utilities.DownloadOptions) (string, error) {
+	lock, err := ShareCache()
+	if err != nil {
+		return "", err
+	}
+	defer lock.Unlock()
+	var digest string
+	if options.Sha256 != "" {
+		var err error
+		if digest, err = utilities.NormalizeSha256(options.Sha256); err != nil {
+			return "", utilities.NewError(utilities.PackageError, "cannot cache "+url, err)
+		}
+	} else {
+		digest, _ = findArtifactByURL(url)
+	}
+	if digest != "" && utilities.IsFileExists(artifactPath(digest)) {
+		if options.Progress == nil {
+			utilities.Formatter.Info(filepath.Base(url) + " found in cache")
+		}
+		return digest, nil
+	}
+	downloadKey := digest
+	if downloadKey == "" {
+		urlDigest := sha256.Sum256([]byte(url))
+		downloadKey = hex.EncodeToString(urlDigest[:])
+	}
+	downloadFolder := filepath.Join(utilities.GetFolder(utilities.CACHEFOLDER), downloadsFolder, downloadKey)
+	// other processes may be downloading the same artifact into the same folder
+	downloadLock, err := utilities.LockFile(downloadFolder+lockSuffix, false)
+	if err != nil {
+		return "", utilities.NewError(utilities.PackageError, "cannot lock the download of "+url, err)
+	}
+	defer downloadLock.Unlock()
+	if digest == "" {
+		digest, _ = findArtifactByURL(url)
+	}
+	if digest != "" && utilities.IsFileExists(artifactPath(digest)) {
+		return digest, nil
+	}
+	if options.Open == nil {
+		opener, err := system.ArtifactOpener(url)
+		if err != nil {
+			return "", err
+		}
+		options.Open = opener
+	}
+	if err := utilities.DownloadWithOptions(ctx, url, downloadFolder, options); err != nil {
+		return "", err
+	}
+	name := filepath.Base(strings.SplitN(url, "?", 2)[0])
+	downloaded := filepath.Join(downloadFolder, name)
+	if digest == "" {
+		if digest, err = utilities.Sha256File(downloaded); err != nil {
+			return "", utilities.NewError(utilities.PackageError, "cannot compute the digest of "+name, err)
+		}
+	}
+	if utilities.IsFileExists(artifactPath(digest)) {
+		// the same content has been cached from another url
+		os.RemoveAll(downloadFolder)
+		return digest, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(artifactPath(digest)), os.ModePerm); err != nil {
+		return "", utilities.NewError(utilities.PackageError, "cannot create the cache folder", err)
+	}
+	info, err := os.Stat(downloaded)
+	if err != nil {
+		return "", utilities.NewError(utilities.PackageError, "cannot read "+downloaded, err)
+	}
+	if err := os.Rename(downloaded, artifactPath(digest)); err != nil {
+		return "", utilities.NewError(utilities.PackageError, "cannot move "+name+" into the cache", err)
+	}
+	os.RemoveAll(downloadFolder)
+	// artifacts are shared by hardlinks, they should not be modified in place
+	os.Chmod(artifactPath(digest), 0444)
+	meta, _ := json.Marshal(CachedArtifact{Digest: digest, Name: name, URL: url, Size: info.Size(), AddedAt: time.Now()})
+	if err := ioutil.WriteFile(artifactPath(digest)+metaSuffix, meta, 0644); err != nil {
+		return "", utilities.NewError(utilities.PackageError, "cannot write the metadata of "+name, err)
+	}
+	return digest, nil
+}
+
+// ShareCache keeps the cached artifacts from being pruned until the lock is
+// released, it should be held from caching artifacts until they are linked
+func ShareCache() (*utilities.FileLock, error) {
+	lock, err := utilities.LockFile(filepath.Join(utilities.GetFolder(utilities.CACHEFOLDER), cacheLock), true)
+	if err != nil {
+		return nil, utilities.NewError(utilities.PackageError, "cannot lock the cache", err)
+	}
+	return lock, nil
+}
+
+// LinkArtifact makes the cached artifact available at target
+func LinkArtifact(digest string, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return utilities.NewError(utilities.PackageError, "cannot create the folder "+filepath.Dir(target), err)
+	}
+	os.Remove(target)
+	if err := os.Link(artifactPath(digest), target); err == nil {
+		return nil
+	}
+	// e.g. the cache and the package are on different devices
+	if err := os.Symlink(artifactPath(digest), target); err != nil {
+		return utilities.NewError(utilities.PackageError, "cannot link "+digest+" to "+target, err)
+	}
+	return nil
+}
+
+// ListArtifacts returns all cached artifacts and the packages using them
+func ListArtifacts() ([]CachedArtifact, error) {
+	folder := filepath.Join(utilities.GetFolder(utilities.CACHEFOLDER), artifactsFolder)
+	entries, err := ioutil.ReadDir(folder)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, utilities.NewError(utilities.PackageError, "cannot read the cache folder", err)
+	}
+	var artifacts []CachedArtifact
+	blobs := make(map[string]os.FileInfo)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), metaSuffix) {
+			continue
+		}
+		artifact := CachedArtifact{Digest: entry.Name(), Size: entry.Size(), AddedAt: entry.ModTime()}
+		if meta, err := ioutil.ReadFile(filepath.Join(folder, entry.Name()+metaSuffix)); err == nil {
+			json.Unmarshal(meta, &artifact)
+		}
+		artifacts = append(artifacts, artifact)
+		blobs[entry.Name()] = entry
+	}
+	usedBy := artifactUsers(blobs)
+	for idx := range artifacts {
+		artifacts[idx].UsedBy = usedBy[artifacts[idx].Digest]
+	}
+	return artifacts, nil
+}
+
+// PruneArtifacts removes the cached artifacts that no package is using,
+// including the packages being installed. It fails if artifacts are being
+// cached or linked at the same time.
+func PruneArtifacts() ([]CachedArtifact, error) {
+	lock, err := utilities.TryLockFile(filepath.Join(utilities.GetFolder(utilities.CACHEFOLDER), cacheLock))
+	if err != nil {
+		return nil, utilities.NewError(utilities.PackageError, "cannot lock the cache", err)
+	}
+	if lock == nil {
+		return nil, utilities.NewError(utilities.PackageError, "pretrained models are being downloaded, try again once the installations are finished", nil)
+	}
+	defer lock.Unlock()
+	artifacts, err := ListArtifacts()
+	if err != nil {
+		return nil, err
+	}
+	var pruned []CachedArtifact
+	for _, artifact := range artifacts {
+		if len(artifact.UsedBy) > 0 {
+			continue
+		}
+		if err := os.Remove(artifactPath(artifact.Digest)); err != nil {
+			return pruned, utilities.NewError(utilities.PackageError, "cannot remove "+artifact.Digest, err)
+		}
+		os.Remove(artifactPath(artifact.Digest) + metaSuffix)
+		pruned = append(pruned, artifact)
+	}
+	// unfinished downloads are pruned as well
+	os.RemoveAll(filepath.Join(utilities.GetFolder(utilities.CACHEFOLDER), downloadsFolder))
+	return pruned, nil
+}
+
+// VerifyArtifacts recomputes the digest of every cached artifact and
+// returns the ones whose content does not match their digest
+func VerifyArtifacts() ([]CachedArtifact, error) {
+	artifacts, err := ListArtifacts()
+	if err != nil {
+		return nil, err
+	}
+	var corrupted []CachedArtifact
+	for _, artifact := range artifacts {
+		if err := utilities.VerifySha256(artifactPath(artifact.Digest), artifact.Digest); err != nil {
+			corrupted = append(corrupted, artifact)
+		}
+	}
+	return corrupted, nil
+}
+
+// CacheSizeMB returns the size of all cached artifacts
+func CacheSizeMB() float64 {
+	return utilities.GetDirSizeMB(filepath.Join(utilities.GetFolder(utilities.CACHEFOLDER), artifactsFolder))
+}
+
+// findArtifactByURL returns the digest of the artifact cached from url
+func findArtifactByURL(url string) (string, bool) {
+	metaFiles, _ := filepath.Glob(filepath.Join(utilities.GetFolder(utilities.CACHEFOLDER), artifactsFolder, "*"+metaSuffix))
+	for _, metaFile := range metaFiles {
+		var artifact CachedArtifact
+		meta, err := ioutil.ReadFile(metaFile)
+		if err != nil || json.Unmarshal(meta, &artifact) != nil {
+			continue
+		}
+		if artifact.URL == url && utilities.IsFileExists(artifactPath(artifact.Digest)) {
+			return artifact.Digest, true
+		}
+	}
+	return "", false
+}
+
+func artifactPath(digest string) string {
+	return filepath.Join(utilities.GetFolder(utilities.CACHEFOLDER), artifactsFolder, digest)
+}
+
+// artifactUsers maps digests to the packages that have a link to the artifact,
+// packages are stored under [models]/[vendor]/[name]. Packages being
+// installed or upgraded are staged in the temp folder and listed as stagingUser.
+func artifactUsers(blobs map[string]os.FileInfo) map[string][]string {
+	usedBy := make(map[string][]string)
+	if len(blobs) == 0 {
+		return usedBy
+	}
+	addUser := func(digest string, packageName string) {
+		if users := usedBy[digest]; len(users) == 0 || users[len(users)-1] != packageName {
+			usedBy[digest] = append(users, packageName)
+		}
+	}
+	walkLinks(utilities.GetFolder("temp"), blobs, func(digest string, segments []string) {
+		addUser(digest, stagingUser)
+	})
+	walkLinks(utilities.GetFolder(utilities.MODELSFOLDER), blobs, func(digest string, segments []string) {
+		if len(segments) > 2 {
+			addUser(digest, segments[0]+"/"+segments[1])
+		}
+	})
+	return usedBy
+}
+
+// walkLinks calls found with the digest and the path segments, relative to
+// folder, of every file in folder that is a link to one of the blobs
+func walkLinks(folder string, blobs map[string]os.FileInfo, found func(digest string, segments []string)) {
+	filepath.Walk(folder, func(path string, file os.FileInfo, err error) error {
+		if err != nil || file.IsDir() {
+			return nil
+		}
+		// follows symlinks
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil
+		}
+		for digest, blob := range blobs {
+			if info.Size() != blob.Size() || !os.SameFile(info, blob) {
+				continue
+			}
+			relPath, _ := filepath.Rel(folder, path)
+			found(digest, strings.Split(filepath.ToSlash(relPath), "/"))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package cargo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// DefaultDownloadConcurrency is the number of parallel downloads if none is configured
+const DefaultDownloadConcurrency = 3
+
+// progressInterval limits how often progress events are sent for a single download
+const progressInterval = 200 * time.Millisecond
+
+const (
+	// DownloadQueued means the download is waiting for a free slot
+	DownloadQueued = "queued"
+	// DownloadRunning means the artifact is being downloaded
+	DownloadRunning = "downloading"
+	// DownloadDone means the artifact is in the cache
+	DownloadDone = "done"
+	// DownloadFailed means the artifact cannot be downloaded
+	DownloadFailed = "failed"
+)
+
+// ArtifactRequest is an artifact to be downloaded into the cache
+type ArtifactRequest struct {
+	Name   string
+	URL    string
+	Sha256 string
+	Size   int64
+	// Open reads the artifact instead of the source of the url, if it is set
+	Open utilities.Opener
+}
+
+// DownloadEvent reports the progress of an artifact download, Total is -1 if it is unknown
+type DownloadEvent struct {
+	Package    string `json:"package"`
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	State      string `json:"state"`
+	Downloaded int64  `json:"downloaded"`
+	Total      int64  `json:"total"`
+	Error      string `json:"error,omitempty"`
+}
+
+// downloadHub passes download events to all subscribers
+var downloadHub = struct {
+	mu       sync.Mutex
+	nextID   int
+	handlers map[int]func(DownloadEvent)
+}{handlers: make(map[int]func(DownloadEvent))}
+
+// SubscribeDownloads calls handler with the events of all downloads started
+// by CacheArtifacts and returns a function to unsubscribe. Handlers are
+// called one at a time, before the download continues, so they should
+// return quickly.
+func SubscribeDownloads(handler func(DownloadEvent)) func() {
+	downloadHub.mu.Lock()
+	id := downloadHub.nextID
+	downloadHub.nextID++
+	downloadHub.handlers[id] = handler
+	downloadHub.mu.Unlock()
+	return func() {
+		downloadHub.mu.Lock()
+		delete(downloadHub.handlers, id)
+		downloadHub.mu.Unlock()
+	}
+}
+
+func publishDownload(event DownloadEvent) {
+	downloadHub.mu.Lock()
+	defer downloadHub.mu.Unlock()
+	for _, handler := range downloadHub.handlers {
+		handler(event)
+	}
+}
+
+// CacheArtifacts downloads the artifacts of the package into the cache, at most
+// concurrency at a time, and returns their digests in the same order. The
+// remaining downloads are cancelled once one of them fails.
+func CacheArtifacts(ctx context.Context, packageName string, requests []ArtifactRequest, concurrency int) ([]string, error) {
+	if concurrency < 1 {
+		concurrency = DefaultDownloadConcurrency
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	digests := make([]string, len(requests))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for idx, request := range requests {
+		publishDownload(DownloadEvent{Package: packageName, Name: request.Name, URL: request.URL, State: DownloadQueued, Total: request.Size})
+		wg.Add(1)
+		go func(idx int, request ArtifactRequest) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				publishDownload(DownloadEvent{Package: packageName, Name: request.Name, URL: request.URL, State: DownloadFailed, Total: request.Size, Error: ctx.Err().Error()})
+				return
+			}
+			event := DownloadEvent{Package: packageName, Name: request.Name, URL: request.URL, State: DownloadRunning, Total: request.Size}
+			publishDownload(event)
+			var lastPublished time.Time
+			digest, err := CacheArtifact(ctx, request.URL, utilities.DownloadOptions{
+				Sha256: request.Sha256,
+				Size:   request.Size,
+				Open:   request.Open,
+				Progress: func(downloaded int64, total int64) {
+					if time.Since(lastPublished) < progressInterval {
+						return
+					}
+					lastPublished = time.Now()
+					event.Downloaded, event.Total = downloaded, total
+					publishDownload(event)
+				},
+			})
+			if err != nil {
+				event.State, event.Error = DownloadFailed, err.Error()
+				publishDownload(event)
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			event.State = DownloadDone
+			if event.Total > 0 {
+				event.Downloaded = event.Total
+			}
+			publishDownload(event)
+			digests[idx] = digest
+		}(idx, request)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return digests, nil
+}
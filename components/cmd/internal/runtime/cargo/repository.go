@@ -9,6 +9,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 
 	entRepository "github.com/autoai-org/aid/ent/generated/repository"
 	"github.com/autoai-org/aid/internal/database"
@@ -16,25 +17,33 @@ import (
 )
 
 // RemovePackage deletes the source code and pretrained models of a package
-// It also removes the database records.
+// It also removes the database records. Packages that others depend on are not removed.
 func RemovePackage(packageID string) error {
 	repo, err := database.NewDefaultDB().Repository.Query().Where(entRepository.UID(packageID)).First(context.Background())
 	if err != nil {
-		utilities.Formatter.Error("Cannot fetch repository " + repo.Vendor + "/" + repo.Name + "(" + fmt.Sprint(packageID) + ") from database.")
-		os.Exit(3)
+		return utilities.NewError(utilities.DatabaseError, "cannot fetch repository "+packageID+" from database", err)
+	}
+	dependents, err := repo.QueryDependents().All(context.Background())
+	if err != nil {
+		return utilities.NewError(utilities.DatabaseError, "cannot fetch dependents of "+packageID, err)
+	}
+	if len(dependents) > 0 {
+		var names []string
+		for _, dependent := range dependents {
+			names = append(names, dependent.Vendor+"/"+dependent.Name+"("+dependent.UID+")")
+		}
+		return utilities.NewError(utilities.RequestError, repo.Vendor+"/"+repo.Name+" is required by "+strings.Join(names, ", "), nil)
 	}
 	_, err = database.NewDefaultDB().Repository.Delete().Where(entRepository.UID(packageID)).Exec(context.Background())
 	if err != nil {
-		utilities.ReportError(err, "Cannot remove container")
-		return err
+		return utilities.NewError(utilities.DatabaseError, "cannot remove repository "+packageID+" from database", err)
 	}
 	utilities.Formatter.Info("Repository: " + repo.Vendor + "/" + repo.Name + "(" + fmt.Sprint(packageID) + ") deleted from database.")
 	// Now remove files on the disk
 	err = os.RemoveAll(repo.Localpath)
 	if err != nil {
-		utilities.Formatter.Error("Cannot delete the folder " + repo.Localpath + ": " + err.Error())
-		os.Exit(6)
+		return utilities.NewError(utilities.PackageError, "cannot delete the folder "+repo.Localpath, err)
 	}
 	utilities.Formatter.Info("Repository: " + repo.Vendor + "/" + repo.Name + "(" + fmt.Sprint(packageID) + ") deleted from your disk.")
-	return err
+	return nil
 }
@@ -7,68 +7,171 @@ package docker
 
 import (
 	"context"
-	"os"
+	"regexp"
+	"strings"
 
+	ent "github.com/autoai-org/aid/ent/generated"
 	entContainer "github.com/autoai-org/aid/ent/generated/container"
 	entImage "github.com/autoai-org/aid/ent/generated/image"
+	entRepository "github.com/autoai-org/aid/ent/generated/repository"
+	entSolver "github.com/autoai-org/aid/ent/generated/solver"
+	"github.com/autoai-org/aid/internal/configuration"
 	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/runtime/requests"
 	"github.com/autoai-org/aid/internal/utilities"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/go-connections/nat"
 )
 
+// containerNamePattern matches the container names accepted by docker
+var containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// ContainerOptions are the runtime settings of a container, zero values use the docker defaults
+type ContainerOptions struct {
+	// Name is the name of the container, a unique one is generated if it is empty
+	Name string
+	// BindAddress is the host address the port is published on, 0.0.0.0 if it is empty
+	BindAddress string
+	// Env holds KEY=VALUE pairs
+	Env []string
+	// Volumes holds [host path]:[container path][:ro] bindings with absolute host paths
+	Volumes []string
+	// Memory is the memory limit in bytes
+	Memory int64
+	// CPUs is the number of CPUs the container may use
+	CPUs float64
+	// Restart is the restart policy, e.g. unless-stopped or on-failure:3
+	Restart string
+}
+
 // Create creates a docker container
-func Create(imageUID string, hostPort string) (container.ContainerCreateCreatedBody, error) {
+func Create(imageUID string, hostPort string, options ContainerOptions) (*ent.Container, error) {
 	image, err := database.NewDefaultDB().Image.Query().Where(entImage.UID(imageUID)).First(context.Background())
 	if err != nil {
-		utilities.Formatter.Error("Cannot fetch image " + imageUID + ", Aborted")
-		os.Exit(3)
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch image "+imageUID, err)
+	}
+	name := options.Name
+	if name == "" {
+		name = generateContainerName(image.Title)
+	} else if !containerNamePattern.MatchString(name) {
+		return nil, utilities.NewError(utilities.RequestError, "invalid container name "+name+", only letters, digits, '_', '.' and '-' are allowed", nil)
+	}
+	exists, err := database.NewDefaultDB().Container.Query().Where(entContainer.Name(name)).Exist(context.Background())
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch container "+name, err)
+	}
+	if exists {
+		return nil, utilities.NewError(utilities.RequestError, "the container name "+name+" is already in use", nil)
+	}
+	restartPolicy, maxRetries, err := configuration.ParseRestartPolicy(options.Restart)
+	if err != nil {
+		return nil, utilities.NewError(utilities.RequestError, "invalid restart policy", err)
+	}
+	bindAddress := options.BindAddress
+	if bindAddress == "" {
+		bindAddress = "0.0.0.0"
 	}
 	hostConfig := &container.HostConfig{
 		PortBindings: nat.PortMap{
 			"8080/tcp": []nat.PortBinding{
 				{
-					HostIP:   "0.0.0.0",
+					HostIP:   bindAddress,
 					HostPort: hostPort,
 				},
 			},
 		},
+		Binds: options.Volumes,
+		RestartPolicy: container.RestartPolicy{
+			Name:              restartPolicy,
+			MaximumRetryCount: maxRetries,
+		},
+		Resources: container.Resources{
+			Memory:   options.Memory,
+			NanoCPUs: int64(options.CPUs * 1e9),
+		},
+	}
+	cli, err := NewDockerRuntime()
+	if err != nil {
+		return nil, err
 	}
-	resp, err := NewDockerRuntime().ContainerCreate(context.Background(), &container.Config{
-		Image: image.UID,
-		Tty:   true,
+	resp, err := cli.ContainerCreate(context.Background(), &container.Config{
+		Image:  image.UID,
+		Tty:    true,
+		Env:    options.Env,
+		Labels: containerLabels(image),
 		ExposedPorts: nat.PortSet{
 			"8080/tcp": struct{}{},
 		},
-	}, hostConfig, nil, nil, image.UID)
+	}, hostConfig, nil, nil, name)
 	if err != nil {
-		utilities.ReportError(err, "Cannot create container from image "+image.UID)
-		return resp, err
+		return nil, utilities.NewError(utilities.DockerError, "cannot create container from image "+image.UID, err)
 	}
-	_, err = database.NewDefaultDB().Container.Create().SetUID(resp.ID[0:10]).SetPort(hostPort).AddImage(image).Save(context.Background())
-	utilities.ReportError(err, "Cannot save "+resp.ID)
-	utilities.Formatter.Info("Successfully created container for " + image.Title)
+	containerEnt, err := database.NewDefaultDB().Container.Create().
+		SetUID(resp.ID[0:10]).
+		SetPort(hostPort).
+		SetName(name).
+		SetBindAddress(bindAddress).
+		SetEnv(options.Env).
+		SetVolumes(options.Volumes).
+		SetMemory(options.Memory).
+		SetCpus(options.CPUs).
+		SetRestart(options.Restart).
+		SetImage(image).
+		Save(context.Background())
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot save container "+resp.ID, err)
+	}
+	utilities.Formatter.Info("Successfully created container " + name + " for " + image.Title)
 	utilities.Formatter.Info("The reference for the created container is " + resp.ID[0:10])
-	return resp, err
+	return containerEnt, nil
+}
+
+// containerLabels returns the provenance labels of the image, the build id
+// is inherited from the image by docker
+func containerLabels(image *ent.Image) map[string]string {
+	solver, err := image.QuerySolver().WithRepository().Only(context.Background())
+	if err != nil || solver.Edges.Repository == nil {
+		// e.g. the package has been removed
+		return map[string]string{LabelManaged: "true"}
+	}
+	return provenanceLabels(solver.Edges.Repository, solver.Name)
+}
+
+// generateContainerName derives a name from the image title, e.g.
+// aid/vendor/package/solver becomes aid-vendor-package-solver-[random]
+func generateContainerName(imageTitle string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, imageTitle)
+	if name = strings.Trim(name, "-_."); name == "" {
+		name = "aid"
+	}
+	return name + "-" + utilities.GenerateUUIDv4()[0:8]
 }
 
 // Start will start a docker container
 func Start(containerID string) error {
 	containerEnt, err := database.NewDefaultDB().Container.Query().Where(entContainer.UID(containerID)).First(context.Background())
 	if err != nil {
-		utilities.Formatter.Error("Cannot fetch container: " + err.Error())
-		os.Exit(3)
+		return utilities.NewError(utilities.DatabaseError, "cannot fetch container "+containerID, err)
 	}
 	if containerEnt.Running {
-		utilities.Formatter.Error("The requested container has already been started: " + containerEnt.UID)
-		os.Exit(4)
+		return utilities.NewError(utilities.RequestError, "the requested container has already been started: "+containerEnt.UID, nil)
 	}
-	if err := NewDockerRuntime().ContainerStart(context.Background(), containerEnt.UID, types.ContainerStartOptions{}); err != nil {
-		utilities.ReportError(err, "Cannot start container "+containerID)
+	cli, err := NewDockerRuntime()
+	if err != nil {
 		return err
 	}
-	containerEnt.Update().SetRunning(true).Save(context.Background())
+	if err := cli.ContainerStart(context.Background(), containerEnt.UID, types.ContainerStartOptions{}); err != nil {
+		return utilities.NewError(utilities.DockerError, "cannot start container "+containerID, err)
+	}
+	if _, err = containerEnt.Update().SetRunning(true).SetHealth(requests.HealthStarting).Save(context.Background()); err != nil {
+		return utilities.NewError(utilities.DatabaseError, "cannot update container "+containerID, err)
+	}
 	return nil
 }
 
@@ -76,21 +179,21 @@ func Start(containerID string) error {
 func Stop(containerID string) error {
 	containerEnt, err := database.NewDefaultDB().Container.Query().Where(entContainer.UID(containerID)).First(context.Background())
 	if err != nil {
-		utilities.Formatter.Error("Cannot fetch container: " + err.Error())
-		os.Exit(3)
+		return utilities.NewError(utilities.DatabaseError, "cannot fetch container "+containerID, err)
 	}
 	if !containerEnt.Running {
-		utilities.Formatter.Error("The requested container is not running: " + containerEnt.UID)
-		os.Exit(4)
+		return utilities.NewError(utilities.RequestError, "the requested container is not running: "+containerEnt.UID, nil)
 	}
-	if err := NewDockerRuntime().ContainerStop(context.Background(), containerEnt.UID, nil); err != nil {
-		utilities.ReportError(err, "Cannot start container "+containerID)
+	cli, err := NewDockerRuntime()
+	if err != nil {
 		return err
 	}
-	_, err = containerEnt.Update().SetRunning(false).Save(context.Background())
+	if err := cli.ContainerStop(context.Background(), containerEnt.UID, nil); err != nil {
+		return utilities.NewError(utilities.DockerError, "cannot stop container "+containerID, err)
+	}
+	_, err = containerEnt.Update().SetRunning(false).ClearHealth().Save(context.Background())
 	if err != nil {
-		utilities.ReportError(err, "Cannot stop the container")
-		return err
+		return utilities.NewError(utilities.DatabaseError, "cannot update container "+containerID, err)
 	}
 	utilities.Formatter.Info("Successfully stopped " + containerID)
 	return nil
@@ -100,22 +203,40 @@ func Stop(containerID string) error {
 func RemoveContainer(containerID string) error {
 	containerEnt, err := database.NewDefaultDB().Container.Query().Where(entContainer.UID(containerID)).First(context.Background())
 	if err != nil {
-		utilities.Formatter.Error("Cannot fetch container: " + err.Error())
-		os.Exit(3)
+		return utilities.NewError(utilities.DatabaseError, "cannot fetch container "+containerID, err)
 	}
 	if containerEnt.Running {
-		utilities.Formatter.Error("The requested container is running: " + containerEnt.UID + ". You must stop it first.")
-		os.Exit(4)
+		return utilities.NewError(utilities.RequestError, "the requested container is running: "+containerEnt.UID+", you must stop it first", nil)
 	}
-	if err := NewDockerRuntime().ContainerRemove(context.Background(), containerEnt.UID, types.ContainerRemoveOptions{}); err != nil {
-		utilities.ReportError(err, "Cannot remove container "+containerID)
+	cli, err := NewDockerRuntime()
+	if err != nil {
 		return err
 	}
+	if err := cli.ContainerRemove(context.Background(), containerEnt.UID, types.ContainerRemoveOptions{}); err != nil {
+		return utilities.NewError(utilities.DockerError, "cannot remove container "+containerID, err)
+	}
 	_, err = database.NewDefaultDB().Container.Delete().Where(entContainer.UID(containerID)).Exec(context.Background())
 	if err != nil {
-		utilities.ReportError(err, "Cannot remove container")
-		return err
+		return utilities.NewError(utilities.DatabaseError, "cannot remove container "+containerID+" from database", err)
 	}
 	utilities.Formatter.Info("Successfully removed the container " + containerID)
-	return err
+	return nil
+}
+
+// RunningContainers returns the running containers of the solver, found
+// through their image, its solver and the package of the solver
+func RunningContainers(vendor string, packageName string, solverName string) ([]*ent.Container, error) {
+	containers, err := database.NewDefaultDB().Container.Query().
+		Where(
+			entContainer.Running(true),
+			entContainer.HasImageWith(entImage.HasSolverWith(
+				entSolver.Name(solverName),
+				entSolver.HasRepositoryWith(entRepository.Vendor(vendor), entRepository.Name(packageName)),
+			)),
+		).
+		All(context.Background())
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch the containers of "+vendor+"/"+packageName+"/"+solverName, err)
+	}
+	return containers, nil
 }
@@ -0,0 +1,178 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	entContainer "github.com/autoai-org/aid/ent/generated/container"
+	entEvent "github.com/autoai-org/aid/ent/generated/event"
+	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/runtime/requests"
+	"github.com/autoai-org/aid/internal/utilities"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+const (
+	// eventRetention is how long events are kept in the database
+	eventRetention = 7 * 24 * time.Hour
+	// eventPruneInterval is how often old events are removed
+	eventPruneInterval = time.Hour
+	// eventReconnectDelay is the wait before reconnecting to docker
+	eventReconnectDelay = 5 * time.Second
+	// defaultEventLimit is the number of events returned by ListEvents if no limit is given
+	defaultEventLimit = 100
+)
+
+// EventFilter selects the events returned by ListEvents, zero values match all events
+type EventFilter struct {
+	// Type is container or image
+	Type string
+	// Actor is the unique id of the container or image
+	Actor  string
+	Action string
+	Since  time.Time
+	Limit  int
+}
+
+// WatchEvents records the docker events of the images and containers managed
+// by aid and keeps the state of the containers up to date until ctx is done.
+// The watcher reconnects and resumes from the last event if docker is restarted.
+func WatchEvents(ctx context.Context) {
+	since := time.Now()
+	lastPruned := time.Time{}
+	for {
+		since = watchEvents(ctx, since, &lastPruned)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eventReconnectDelay):
+		}
+	}
+}
+
+// watchEvents handles events until the connection is lost and returns the time to resume from
+func watchEvents(ctx context.Context, since time.Time, lastPruned *time.Time) time.Time {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cli, err := NewDockerRuntime()
+	if err != nil {
+		utilities.Formatter.Warn("Cannot watch docker events: " + err.Error())
+		return since
+	}
+	messages, errs := cli.Events(ctx, types.EventsOptions{
+		Since:   fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()),
+		Filters: filters.NewArgs(filters.Arg("label", LabelManaged+"=true")),
+	})
+	for {
+		select {
+		case message := <-messages:
+			if err := handleEvent(message); err != nil {
+				utilities.Formatter.Warn("Cannot handle docker event " + message.Type + " " + message.Action + ": " + err.Error())
+			}
+			// the next connection starts right after this event
+			since = time.Unix(0, message.TimeNano+1)
+			if time.Since(*lastPruned) > eventPruneInterval {
+				*lastPruned = time.Now()
+				pruneEvents()
+			}
+		case err := <-errs:
+			if ctx.Err() == nil {
+				utilities.Formatter.Warn("Lost connection to docker events, reconnecting: " + err.Error())
+			}
+			return since
+		}
+	}
+}
+
+// handleEvent records the event and updates the state of the container
+func handleEvent(message events.Message) error {
+	// health checks create exec events every few seconds
+	if strings.HasPrefix(message.Action, "exec_") {
+		return nil
+	}
+	ctx := context.Background()
+	actor := message.Actor.ID
+	switch message.Type {
+	case events.ContainerEventType:
+		if len(actor) > 10 {
+			actor = actor[0:10]
+		}
+	case events.ImageEventType:
+		actor = shortImageID(actor)
+	default:
+		return nil
+	}
+	_, err := database.NewDefaultDB().Event.Create().
+		SetType(message.Type).
+		SetAction(message.Action).
+		SetActor(actor).
+		SetName(message.Actor.Attributes["name"]).
+		SetAttributes(message.Actor.Attributes).
+		SetCreatedAt(time.Unix(0, message.TimeNano)).
+		Save(ctx)
+	if err != nil {
+		return utilities.NewError(utilities.DatabaseError, "cannot save event", err)
+	}
+	if message.Type != events.ContainerEventType {
+		return nil
+	}
+	switch {
+	case message.Action == "start":
+		_, err = database.NewDefaultDB().Container.Update().Where(entContainer.UID(actor)).SetRunning(true).SetHealth(requests.HealthStarting).Save(ctx)
+	case message.Action == "die":
+		_, err = database.NewDefaultDB().Container.Update().Where(entContainer.UID(actor)).SetRunning(false).ClearHealth().Save(ctx)
+	case message.Action == "destroy":
+		_, err = database.NewDefaultDB().Container.Delete().Where(entContainer.UID(actor)).Exec(ctx)
+	case strings.HasPrefix(message.Action, "health_status: "):
+		// the results of the HEALTHCHECK in the dockerfile
+		health := strings.TrimPrefix(message.Action, "health_status: ")
+		_, err = database.NewDefaultDB().Container.Update().Where(entContainer.UID(actor)).SetHealth(health).Save(ctx)
+	}
+	if err != nil {
+		return utilities.NewError(utilities.DatabaseError, "cannot update container "+actor, err)
+	}
+	return nil
+}
+
+// pruneEvents removes the events older than eventRetention
+func pruneEvents() {
+	_, err := database.NewDefaultDB().Event.Delete().Where(entEvent.CreatedAtLT(time.Now().Add(-eventRetention))).Exec(context.Background())
+	if err != nil {
+		utilities.Formatter.Warn("Cannot remove old events: " + err.Error())
+	}
+}
+
+// ListEvents returns the recorded events matching the filter, the latest first
+func ListEvents(filter EventFilter) ([]*ent.Event, error) {
+	query := database.NewDefaultDB().Event.Query()
+	if filter.Type != "" {
+		query = query.Where(entEvent.Type(filter.Type))
+	}
+	if filter.Actor != "" {
+		query = query.Where(entEvent.ActorHasPrefix(filter.Actor))
+	}
+	if filter.Action != "" {
+		query = query.Where(entEvent.Action(filter.Action))
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where(entEvent.CreatedAtGTE(filter.Since))
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultEventLimit
+	}
+	events, err := query.Order(ent.Desc(entEvent.FieldCreatedAt)).Limit(filter.Limit).All(context.Background())
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch events", err)
+	}
+	return events, nil
+}
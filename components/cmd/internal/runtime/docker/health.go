@@ -0,0 +1,91 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package docker
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	entContainer "github.com/autoai-org/aid/ent/generated/container"
+	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/runtime/requests"
+	"github.com/autoai-org/aid/internal/utilities"
+	"github.com/docker/docker/api/types"
+)
+
+// healthPollInterval is the wait between two health checks in WaitHealthy
+const healthPollInterval = time.Second
+
+// CheckHealth returns the health of a running container and saves it.
+// The docker HEALTHCHECK of the image is used if there is one, images
+// built before it was added to the dockerfile are probed over http.
+func CheckHealth(ctx context.Context, containerEnt *ent.Container) (string, error) {
+	cli, err := NewDockerRuntime()
+	if err != nil {
+		return "", err
+	}
+	inspection, err := cli.ContainerInspect(ctx, containerEnt.UID)
+	if err != nil {
+		return "", utilities.NewError(utilities.DockerError, "cannot inspect container "+containerEnt.UID, err)
+	}
+	if inspection.State == nil || !inspection.State.Running {
+		return "", containerExited(containerEnt.UID, inspection.State)
+	}
+	health := requests.HealthHealthy
+	if inspection.State.Health != nil {
+		health = inspection.State.Health.Status
+	} else if err := requests.ProbeSolver(ctx, requests.SolverAddress(containerEnt)); err != nil {
+		health = requests.HealthStarting
+		if containerEnt.Health == requests.HealthHealthy || containerEnt.Health == requests.HealthUnhealthy {
+			// the solver has answered before
+			health = requests.HealthUnhealthy
+		}
+	}
+	if health != containerEnt.Health {
+		if _, err := containerEnt.Update().SetHealth(health).Save(ctx); err != nil {
+			return health, utilities.NewError(utilities.DatabaseError, "cannot update container "+containerEnt.UID, err)
+		}
+	}
+	return health, nil
+}
+
+// WaitHealthy blocks until the solver in the container answers requests.
+// It fails if the container exits, becomes unhealthy or timeout expires.
+func WaitHealthy(containerID string, timeout time.Duration) error {
+	containerEnt, err := database.NewDefaultDB().Container.Query().Where(entContainer.UID(containerID)).First(context.Background())
+	if err != nil {
+		return utilities.NewError(utilities.DatabaseError, "cannot fetch container "+containerID, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for {
+		health, err := CheckHealth(ctx, containerEnt)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		switch health {
+		case requests.HealthHealthy:
+			return nil
+		case requests.HealthUnhealthy:
+			return utilities.NewError(utilities.DockerError, "container "+containerID+" is unhealthy, see aid logs "+containerID, nil)
+		}
+		select {
+		case <-ctx.Done():
+			return utilities.NewError(utilities.DockerError, "container "+containerID+" is not ready after "+timeout.String(), ctx.Err())
+		case <-time.After(healthPollInterval):
+		}
+	}
+}
+
+func containerExited(containerID string, state *types.ContainerState) error {
+	message := "container " + containerID + " is not running"
+	if state != nil && state.Status == "exited" {
+		message = "container " + containerID + " exited with code " + strconv.Itoa(state.ExitCode)
+	}
+	return utilities.NewError(utilities.DockerError, message+", see aid logs "+containerID, nil)
+}
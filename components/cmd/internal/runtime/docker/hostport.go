@@ -0,0 +1,103 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package docker
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	entContainer "github.com/autoai-org/aid/ent/generated/container"
+	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// DefaultPortRange is the range host ports are allocated from if none is configured
+const DefaultPortRange = "18000-18999"
+
+// ParsePortRange parses [from]-[to], both ports are included
+func ParsePortRange(portRange string) (int, int, error) {
+	parts := strings.SplitN(portRange, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, utilities.NewError(utilities.RequestError, "invalid port range "+portRange+", expected [from]-[to]", nil)
+	}
+	from, fromErr := strconv.Atoi(strings.TrimSpace(parts[0]))
+	to, toErr := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if fromErr != nil || toErr != nil || from < 1 || to > 65535 || from > to {
+		return 0, 0, utilities.NewError(utilities.RequestError, "invalid port range "+portRange+", expected [from]-[to] between 1 and 65535", nil)
+	}
+	return from, to, nil
+}
+
+// CheckHostPort returns an error if the port is assigned to a container
+// or another process is listening on it
+func CheckHostPort(bindAddress string, hostPort string) error {
+	port, err := strconv.Atoi(hostPort)
+	if err != nil || port < 1 || port > 65535 {
+		return utilities.NewError(utilities.RequestError, "invalid host port "+hostPort, err)
+	}
+	usedBy, err := database.NewDefaultDB().Container.Query().Where(entContainer.Port(hostPort)).First(context.Background())
+	if err == nil {
+		return utilities.NewError(utilities.RequestError, "the host port "+hostPort+" is already assigned to the container "+usedBy.UID, nil)
+	}
+	if !ent.IsNotFound(err) {
+		return utilities.NewError(utilities.DatabaseError, "cannot fetch containers", err)
+	}
+	if !isPortFree(bindAddress, port) {
+		return utilities.NewError(utilities.RequestError, "the host port "+hostPort+" is already in use", nil)
+	}
+	return nil
+}
+
+// AllocateHostPort returns the first port in the range that is neither
+// assigned to a container nor in use by another process
+func AllocateHostPort(bindAddress string, portRange string) (string, error) {
+	if portRange == "" {
+		portRange = DefaultPortRange
+	}
+	from, to, err := ParsePortRange(portRange)
+	if err != nil {
+		return "", err
+	}
+	containers, err := database.NewDefaultDB().Container.Query().All(context.Background())
+	if err != nil {
+		return "", utilities.NewError(utilities.DatabaseError, "cannot fetch containers", err)
+	}
+	assigned := make(map[string]bool)
+	for _, container := range containers {
+		assigned[container.Port] = true
+	}
+	if port, ok := firstFreePort(bindAddress, from, to, assigned); ok {
+		return port, nil
+	}
+	return "", utilities.NewError(utilities.RequestError, "no free host port in "+portRange, nil)
+}
+
+// firstFreePort returns the first port from from to to that is not assigned
+// and not in use by another process
+func firstFreePort(bindAddress string, from int, to int, assigned map[string]bool) (string, bool) {
+	for port := from; port <= to; port++ {
+		if !assigned[strconv.Itoa(port)] && isPortFree(bindAddress, port) {
+			return strconv.Itoa(port), true
+		}
+	}
+	return "", false
+}
+
+// isPortFree tries to listen on the port
+func isPortFree(bindAddress string, port int) bool {
+	if bindAddress == "0.0.0.0" {
+		bindAddress = ""
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(bindAddress, strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
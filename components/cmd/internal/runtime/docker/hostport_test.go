@@ -0,0 +1,159 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package docker
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		portRange string
+		from, to  int
+		wantErr   bool
+	}{
+		{portRange: "18000-18999", from: 18000, to: 18999},
+		{portRange: " 8080 - 8080 ", from: 8080, to: 8080},
+		{portRange: "8080", wantErr: true},
+		{portRange: "9000-8000", wantErr: true},
+		{portRange: "0-100", wantErr: true},
+		{portRange: "60000-70000", wantErr: true},
+		{portRange: "a-b", wantErr: true},
+	}
+	for _, test := range tests {
+		from, to, err := ParsePortRange(test.portRange)
+		if (err != nil) != test.wantErr || from != test.from || to != test.to {
+			t.Errorf("ParsePortRange(%q) = %d, %d, %v, want %d, %d, error %v",
+				test.portRange, from, to, err, test.from, test.to, test.wantErr)
+		}
+	}
+}
+
+// listenRange returns a range of three ports, the first one is in use
+func listenRange(t *testing.T) (int, net.Listener) {
+	for attempt := 0; attempt < 20; attempt++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		from := listener.Addr().(*net.TCPAddr).Port
+		if from+2 <= 65535 && isPortFree("127.0.0.1", from+1) && isPortFree("127.0.0.1", from+2) {
+			return from, listener
+		}
+		listener.Close()
+	}
+	t.Skip("cannot find three consecutive free ports")
+	return 0, nil
+}
+
+func TestFirstFreePort(t *testing.T) {
+	from, listener := listenRange(t)
+	defer listener.Close()
+	port, ok := firstFreePort("127.0.0.1", from, from+2, map[string]bool{})
+	if !ok || port != strconv.Itoa(from+1) {
+		t.Errorf("firstFreePort = %s, %v, want the port after the one in use, %d", port, ok, from+1)
+	}
+	port, ok = firstFreePort("127.0.0.1", from, from+2, map[string]bool{strconv.Itoa(from + 1): true})
+	if !ok || port != strconv.Itoa(from+2) {
+		t.Errorf("firstFreePort = %s, %v, want the port after the assigned one, %d", port, ok, from+2)
+	}
+	_, ok = firstFreePort("127.0.0.1", from, from+2, map[string]bool{strconv.Itoa(from + 1): true, strconv.Itoa(from + 2): true})
+	if ok {
+		t.Error("firstFreePort found a port in a range without free ports")
+	}
+}
+
+// TestConcurrentPortAllocation lets several processes allocate ports the way
+// CreateContainer does, the assigned ports are kept in a file instead of the database
+func TestConcurrentPortAllocation(t *testing.T) {
+	if folder := os.Getenv("AID_TEST_ALLOCATE"); folder != "" {
+		allocatePorts(t, folder)
+		return
+	}
+	folder := t.TempDir()
+	from, listener := listenRange(t)
+	listener.Close()
+	if err := ioutil.WriteFile(filepath.Join(folder, "range"), []byte(strconv.Itoa(from)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	const processes = 4
+	var wg sync.WaitGroup
+	failures := make(chan string, processes)
+	for idx := 0; idx < processes; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=^TestConcurrentPortAllocation$")
+			cmd.Env = append(os.Environ(), "AID_TEST_ALLOCATE="+folder)
+			if output, err := cmd.CombinedOutput(); err != nil {
+				failures <- err.Error() + "\n" + string(output)
+			}
+		}()
+	}
+	wg.Wait()
+	close(failures)
+	for failure := range failures {
+		t.Fatal("allocating process failed: " + failure)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(folder, "assigned"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ports := strings.Fields(string(content))
+	if len(ports) != processes*allocationsPerProcess {
+		t.Fatalf("%d ports were allocated, want %d", len(ports), processes*allocationsPerProcess)
+	}
+	seen := make(map[string]bool)
+	for _, port := range ports {
+		if seen[port] {
+			t.Errorf("port %s was allocated twice", port)
+		}
+		seen[port] = true
+	}
+}
+
+const allocationsPerProcess = 5
+
+func allocatePorts(t *testing.T, folder string) {
+	content, err := ioutil.ReadFile(filepath.Join(folder, "range"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, _ := strconv.Atoi(string(content))
+	for idx := 0; idx < allocationsPerProcess; idx++ {
+		lock, err := utilities.LockFile(filepath.Join(folder, "ports.lock"), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assigned := make(map[string]bool)
+		if content, err := ioutil.ReadFile(filepath.Join(folder, "assigned")); err == nil {
+			for _, port := range strings.Fields(string(content)) {
+				assigned[port] = true
+			}
+		}
+		port, ok := firstFreePort("127.0.0.1", from, 65535, assigned)
+		if !ok {
+			t.Fatal("no free port")
+		}
+		file, err := os.OpenFile(filepath.Join(folder, "assigned"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString(port + "\n")
+		file.Close()
+		lock.Unlock()
+	}
+}
@@ -27,16 +27,21 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func realBuild(dockerfile string, imageName string, buildLogger *logrus.Logger) (types.ImageInspect, error) {
-	buildResponse, err := NewDockerRuntime().ImageBuild(context.Background(), getBuildCtx(path.Dir(dockerfile)), types.ImageBuildOptions{
+func realBuild(ctx context.Context, dockerfile string, imageName string, labels map[string]string, buildLogger *logrus.Logger) (types.ImageInspect, error) {
+	cli, err := NewDockerRuntime()
+	if err != nil {
+		return types.ImageInspect{}, err
+	}
+	buildResponse, err := cli.ImageBuild(ctx, getBuildCtx(path.Dir(dockerfile)), types.ImageBuildOptions{
 		Tags:       []string{strings.ToLower(imageName)},
 		Dockerfile: filepath.Base(dockerfile),
 		Remove:     true,
+		Labels:     labels,
 	})
-	utilities.ReportError(err, "Cannot build image "+imageName)
 	if err != nil {
 		buildLogger.Error("Cannot build image " + imageName)
 		buildLogger.Error(err.Error())
+		return types.ImageInspect{}, utilities.NewError(utilities.DockerError, "cannot build image "+imageName, err)
 	}
 	// set logs to build logs
 	reader := buildResponse.Body
@@ -47,7 +52,7 @@ func realBuild(dockerfile string, imageName string, buildLogger *logrus.Logger)
 		json.Unmarshal(scanner.Bytes(), &buildLog)
 		buildLogger.Info(buildLog.Stream)
 		if utilities.Verbose {
-			fmt.Printf(buildLog.Stream)
+			fmt.Fprint(utilities.LogWriter, buildLog.Stream)
 		}
 	}
 	termFd, isTerm := term.GetFdInfo(os.Stderr)
@@ -56,80 +61,109 @@ func realBuild(dockerfile string, imageName string, buildLogger *logrus.Logger)
 		buildLogger.Error("Cannot show log from " + imageName)
 		buildLogger.Error(err.Error())
 	}
-	imageInspect, _, err := NewDockerRuntime().ImageInspectWithRaw(context.Background(), strings.ToLower(imageName))
+	imageInspect, _, err := cli.ImageInspectWithRaw(context.Background(), strings.ToLower(imageName))
 	if err != nil {
-		utilities.ReportError(err, "Cannot fetch image detail from docker host")
+		return imageInspect, utilities.NewError(utilities.DockerError, "cannot fetch image detail from docker host", err)
 	}
-	return imageInspect, err
+	return imageInspect, nil
 }
 
 // prepareBuild will prepare everything for the building process.
-func prepareBuild(solver ent.Solver) (*ent.SystemLog, error) {
+// If logPath is empty, a new log file will be created under logs/builds.
+func prepareBuild(ctx context.Context, solver ent.Solver, logPath string) (*ent.Image, error) {
 	utilities.Formatter.Info("Building Image for " + solver.Name + " ...")
-	logUID := utilities.GenerateUUIDv4()
-	logPath := filepath.Join(utilities.GetBasePath(), "logs", "builds", logUID[0:10])
-	log, err := database.NewDefaultDB().SystemLog.Create().SetFilepath(logPath).SetTitle(logUID[0:10]).SetSource("build").Save(context.Background())
-	utilities.ReportError(err, "Cannot save to database")
+	if logPath == "" {
+		logPath = filepath.Join(utilities.GetBasePath(), "logs", "builds", utilities.GenerateUUIDv4()[0:10])
+	}
+	_, err := database.NewDefaultDB().SystemLog.Create().SetFilepath(logPath).SetTitle(filepath.Base(logPath)).SetSource("build").Save(context.Background())
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot save build log", err)
+	}
 	utilities.Formatter.Info("Building in progress, view full log at " + logPath)
 	if utilities.Verbose {
 		utilities.Formatter.Info("Verbose mode is on, detailed logs will be shown below.")
 	}
-	buildLogger := utilities.NewLogger(logPath)
+	buildLogger, err := utilities.NewLogger(logPath)
+	if err != nil {
+		return nil, err
+	}
 	repo, err := solver.QueryRepository().First(context.Background())
-	utilities.ReportError(err, "Cannot query repository of "+solver.Name)
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot query repository of "+solver.Name, err)
+	}
 	dockerfile := filepath.Join(repo.Localpath, "docker_"+solver.Name)
 	// if dockerfile does not exists, generate a default one.
 	if !utilities.IsFileExists(dockerfile) {
 		utilities.Formatter.Warn("Dockerfile not found, AID will generate a default version.")
-		GenerateDockerFiles(filepath.Dir(dockerfile))
-		tomlFilePath := filepath.Join(filepath.Dir(dockerfile), "aid.toml")
-		aidToml, err := utilities.ReadFileContent(tomlFilePath)
-		utilities.ReportError(err, "Cannot open file "+tomlFilePath)
-		solvers := configuration.LoadSolversFromConfig(aidToml)
-		RenderRunnerTpl(filepath.Dir(dockerfile), solvers.Solvers)
+		if err := GenerateDockerFiles(filepath.Dir(dockerfile)); err != nil {
+			return nil, err
+		}
+		manifest, err := configuration.ReadManifest(filepath.Dir(dockerfile))
+		if err != nil {
+			return nil, err
+		}
+		if err := RenderRunnerTpl(filepath.Dir(dockerfile), manifest.Solvers); err != nil {
+			return nil, err
+		}
 	}
 	title := "aid/" + repo.Vendor + "/" + repo.Name + "/" + solver.Name
-	inspect, err := realBuild(dockerfile, title, buildLogger)
-	utilities.ReportError(err, "Cannot build image")
-	if err == nil {
-		utilities.Formatter.Info("Finishing building" + solver.Name + " ...")
-		_, err = database.NewDefaultDB().Image.Create().SetUID(inspect.ID[7:17]).SetTitle(title).SetSolver(&solver).Save(context.Background())
-		utilities.ReportError(err, "cannot save image to db")
-		utilities.Formatter.Info("Please use " + inspect.ID[7:17] + " as the reference of the image.")
-	}
-	return log, err
+	labels := provenanceLabels(repo, solver.Name)
+	labels[LabelBuildID] = filepath.Base(logPath)
+	inspect, err := realBuild(ctx, dockerfile, title, labels, buildLogger)
+	if err != nil {
+		return nil, err
+	}
+	utilities.Formatter.Info("Finishing building" + solver.Name + " ...")
+	image, err := database.NewDefaultDB().Image.Create().SetUID(inspect.ID[7:17]).SetTitle(title).SetSolver(&solver).Save(context.Background())
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot save image to database", err)
+	}
+	utilities.Formatter.Info("Please use " + inspect.ID[7:17] + " as the reference of the image.")
+	return image, nil
 }
 
 // BuildImage builds the image
-func BuildImage(vendor string, packageName string, solverName string) {
+func BuildImage(vendor string, packageName string, solverName string) (*ent.Image, error) {
+	return BuildImageContext(context.Background(), vendor, packageName, solverName, "")
+}
+
+// BuildImageContext builds the image and writes the build logs to logPath,
+// the build is aborted once ctx is cancelled.
+func BuildImageContext(ctx context.Context, vendor string, packageName string, solverName string, logPath string) (*ent.Image, error) {
 	repos, err := database.NewDefaultDB().Repository.Query().Where(repository.And(repository.Name(packageName), repository.Vendor(vendor))).First(context.Background())
-	utilities.ReportError(err, "cannot find repos "+packageName)
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot find package "+vendor+"/"+packageName, err)
+	}
 	solvers, err := repos.QuerySolvers().All(context.Background())
-	utilities.ReportError(err, "cannot find solvers of "+packageName)
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot find solvers of "+packageName, err)
+	}
 	for _, solver := range solvers {
 		if solver.Name == solverName {
-			prepareBuild(*solver)
+			return prepareBuild(ctx, *solver, logPath)
 		}
 	}
+	return nil, utilities.NewError(utilities.RequestError, "cannot find solver "+vendor+"/"+packageName+"/"+solverName, nil)
 }
 
 // RemoveImage deletes the image
 func RemoveImage(imageUID string) error {
 	imageEnt, err := database.NewDefaultDB().Image.Query().Where(entImage.UID(imageUID)).First(context.Background())
 	if err != nil {
-		utilities.Formatter.Error("Cannot fetch image from database: " + err.Error())
-		os.Exit(3)
+		return utilities.NewError(utilities.DatabaseError, "cannot fetch image "+imageUID, err)
+	}
+	cli, err := NewDockerRuntime()
+	if err != nil {
+		return err
 	}
-	if _, err := NewDockerRuntime().ImageRemove(context.Background(), imageUID, types.ImageRemoveOptions{}); err != nil {
-		utilities.Formatter.Error("Cannot remove image from docker: " + err.Error())
-		os.Exit(4)
+	if _, err := cli.ImageRemove(context.Background(), imageUID, types.ImageRemoveOptions{}); err != nil {
+		return utilities.NewError(utilities.DockerError, "cannot remove image "+imageUID+" from docker", err)
 	}
 	utilities.Formatter.Info("Image " + imageEnt.Title + "(" + imageUID + ") removed from Docker.")
 	_, err = database.NewDefaultDB().Image.Delete().Where(entImage.UID(imageUID)).Exec(context.Background())
 	if err != nil {
-		utilities.Formatter.Error("Cannot remove image from database: " + err.Error())
-		os.Exit(4)
+		return utilities.NewError(utilities.DatabaseError, "cannot remove image "+imageUID+" from database", err)
 	}
 	utilities.Formatter.Info("Image " + imageEnt.Title + "(" + imageUID + ") removed from database.")
-	return err
+	return nil
 }
@@ -0,0 +1,75 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package docker
+
+import (
+	"context"
+	"strings"
+
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// ContainerState is the state of a container on the docker host
+type ContainerState struct {
+	Status   string `json:"status"`
+	Running  bool   `json:"running"`
+	ExitCode int    `json:"exit_code"`
+	// Health is only set if the image has a HEALTHCHECK
+	Health     string `json:"health,omitempty"`
+	StartedAt  string `json:"started_at,omitempty"`
+	FinishedAt string `json:"finished_at,omitempty"`
+}
+
+// InspectContainer returns the state of the container on the docker host
+func InspectContainer(ctx context.Context, containerID string) (*ContainerState, error) {
+	cli, err := NewDockerRuntime()
+	if err != nil {
+		return nil, err
+	}
+	inspection, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, utilities.NewError(utilities.DockerError, "cannot inspect container "+containerID, err)
+	}
+	state := &ContainerState{}
+	if inspection.State == nil {
+		return state, nil
+	}
+	state.Status = inspection.State.Status
+	state.Running = inspection.State.Running
+	state.ExitCode = inspection.State.ExitCode
+	state.StartedAt = dockerTime(inspection.State.StartedAt)
+	state.FinishedAt = dockerTime(inspection.State.FinishedAt)
+	if inspection.State.Health != nil {
+		state.Health = inspection.State.Health.Status
+	}
+	return state, nil
+}
+
+// ImageBuildID returns the id of the build log of the image, it is empty for
+// images built before the label was added
+func ImageBuildID(ctx context.Context, imageID string) (string, error) {
+	cli, err := NewDockerRuntime()
+	if err != nil {
+		return "", err
+	}
+	inspection, _, err := cli.ImageInspectWithRaw(ctx, imageID)
+	if err != nil {
+		return "", utilities.NewError(utilities.DockerError, "cannot inspect image "+imageID, err)
+	}
+	if inspection.Config == nil {
+		return "", nil
+	}
+	return inspection.Config.Labels[LabelBuildID], nil
+}
+
+// dockerTime leaves out the zero times docker reports for containers that
+// have not started or finished yet
+func dockerTime(t string) string {
+	if strings.HasPrefix(t, "0001-01-01") {
+		return ""
+	}
+	return t
+}
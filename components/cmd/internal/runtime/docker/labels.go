@@ -0,0 +1,55 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package docker
+
+import (
+	ent "github.com/autoai-org/aid/ent/generated"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// The labels set on all images and containers created by aid, they tie
+// the docker objects back to the package and the build they come from
+const (
+	// LabelManaged tells aid objects apart from others on the same docker host
+	LabelManaged = "aid.managed"
+	LabelVendor  = "aid.vendor"
+	LabelPackage = "aid.package"
+	LabelSolver  = "aid.solver"
+	// LabelCommit is the git commit of the package, if it is installed from git
+	LabelCommit = "aid.commit"
+	// LabelVersion is the version of the package, if it is installed from a registry
+	LabelVersion = "aid.version"
+	// LabelBuildID is the id of the build log, it is only set on images and
+	// inherited by their containers
+	LabelBuildID = "aid.build-id"
+)
+
+// provenanceLabels returns the labels of the images and containers of the solver,
+// empty values are left out
+func provenanceLabels(repo *ent.Repository, solverName string) map[string]string {
+	labels := map[string]string{LabelManaged: "true"}
+	for key, value := range map[string]string{
+		LabelVendor:  repo.Vendor,
+		LabelPackage: repo.Name,
+		LabelSolver:  solverName,
+		LabelCommit:  repo.Commit,
+		LabelVersion: repo.Version,
+	} {
+		if value != "" {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// labelFilter matches the objects managed by aid that have all the labels
+func labelFilter(labels map[string]string) filters.Args {
+	args := filters.NewArgs(filters.Arg("label", LabelManaged+"=true"))
+	for key, value := range labels {
+		args.Add("label", key+"="+value)
+	}
+	return args
+}
@@ -12,9 +12,16 @@ import (
 	"github.com/docker/docker/api/types"
 )
 
-// ListImages returns all images that have been installed on the host
-func ListImages() []types.ImageSummary {
-	images, err := Client.ImageList(context.Background(), types.ImageListOptions{})
-	utilities.ReportError(err, "cannot list images")
-	return images
+// ListImages returns the images built by aid that have all the labels, e.g.
+// {LabelVendor: "aidmodels"}. All images built by aid are returned if labels is empty.
+func ListImages(labels map[string]string) ([]types.ImageSummary, error) {
+	cli, err := NewDockerRuntime()
+	if err != nil {
+		return nil, err
+	}
+	images, err := cli.ImageList(context.Background(), types.ImageListOptions{Filters: labelFilter(labels)})
+	if err != nil {
+		return images, utilities.NewError(utilities.DockerError, "cannot list images", err)
+	}
+	return images, nil
 }
@@ -0,0 +1,46 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package docker
+
+import (
+	"context"
+	"io"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// StreamContainerLogs copies stdout and stderr of the container into w.
+// If follow is true, it keeps streaming until ctx is cancelled or the container exits.
+func StreamContainerLogs(ctx context.Context, containerID string, follow bool, w io.Writer) error {
+	cli, err := NewDockerRuntime()
+	if err != nil {
+		return err
+	}
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	reader, err := cli.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	// containers with tty enabled do not multiplex stdout and stderr
+	if inspect.Config != nil && inspect.Config.Tty {
+		_, err = io.Copy(w, reader)
+	} else {
+		_, err = stdcopy.StdCopy(w, w, reader)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
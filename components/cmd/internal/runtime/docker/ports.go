@@ -22,21 +22,28 @@ func ExportImage(imageName string) error {
 	utilities.Formatter.Info("Exporting your image to " + targetFile)
 	// Delete existing file
 	if utilities.IsExists(targetFile) {
-		err := os.Remove(targetFile)
-		utilities.ReportError(err, "Cannot remove existing file "+targetFile)
+		if err := os.Remove(targetFile); err != nil {
+			return utilities.NewError(utilities.UnknownError, "cannot remove existing file "+targetFile, err)
+		}
 	}
 	file, err := os.Create(targetFile)
 	if err != nil {
-		utilities.ReportError(err, "Cannot create new image file at "+targetFile)
-		return err
+		return utilities.NewError(utilities.UnknownError, "cannot create new image file at "+targetFile, err)
 	}
 	defer file.Close()
-	resBody, err := Client.ImageSave(context.Background(), []string{imageName})
-	_, err = io.Copy(file, resBody)
+	cli, err := NewDockerRuntime()
 	if err != nil {
-		utilities.ReportError(err, "Cannot write to the file: "+targetFile)
 		return err
 	}
+	resBody, err := cli.ImageSave(context.Background(), []string{imageName})
+	if err != nil {
+		return utilities.NewError(utilities.DockerError, "cannot save image "+imageName, err)
+	}
+	defer resBody.Close()
+	_, err = io.Copy(file, resBody)
+	if err != nil {
+		return utilities.NewError(utilities.UnknownError, "cannot write to the file "+targetFile, err)
+	}
 	utilities.Formatter.Info("Exported your image to " + targetFile)
 	return nil
 }
@@ -45,21 +52,21 @@ func ExportImage(imageName string) error {
 func ImportImage(imageName string, quiet bool) error {
 	targetFile := filepath.Join(utilities.GetBasePath(), "temp", imageName+".aidimg")
 	utilities.Formatter.Info("Importing your image to " + targetFile)
-	_, err := os.Stat(targetFile)
-	if os.IsNotExist(err) {
-		utilities.ReportError(err, "Cannot read the file: "+targetFile)
-		return err
-	}
 	file, err := os.Open(targetFile)
 	if err != nil {
-		utilities.ReportError(err, "Cannot open the file: "+targetFile)
+		return utilities.NewError(utilities.UnknownError, "cannot open the file "+targetFile, err)
 	}
+	defer file.Close()
 	reader := bufio.NewReader(file)
-	_, err = Client.ImageLoad(context.Background(), reader, quiet)
+	cli, err := NewDockerRuntime()
 	if err != nil {
-		utilities.ReportError(err, "Cannot import image from "+targetFile)
 		return err
 	}
+	resp, err := cli.ImageLoad(context.Background(), reader, quiet)
+	if err != nil {
+		return utilities.NewError(utilities.DockerError, "cannot import image from "+targetFile, err)
+	}
+	resp.Body.Close()
 	utilities.Formatter.Info("Imported your image from " + targetFile)
 	return nil
 }
@@ -0,0 +1,254 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package docker
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	entRepository "github.com/autoai-org/aid/ent/generated/repository"
+	entSolver "github.com/autoai-org/aid/ent/generated/solver"
+	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/utilities"
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	// DriftState means the running state in the database differs from docker
+	DriftState = "state"
+	// DriftMissing means a row has no docker object anymore, e.g. after docker rm
+	DriftMissing = "missing"
+	// DriftOrphan means a docker object built by aid has no row
+	DriftOrphan = "orphan"
+)
+
+// orphanGracePeriod is how old a docker object without a row must be to be
+// reported as an orphan. aid creates the container or image in docker before
+// its row, so younger objects are most likely being saved right now.
+const orphanGracePeriod = time.Minute
+
+// Drift is a difference between the database and docker
+type Drift struct {
+	Kind string `json:"kind"`
+	// Entity is either container or image
+	Entity  string `json:"entity"`
+	UID     string `json:"uid"`
+	Message string `json:"message"`
+	// Fixed is true if the database has been updated to match docker
+	Fixed bool `json:"fixed"`
+}
+
+// Reconcile compares the containers and images in the database with docker
+// and returns the differences. If fix is true the database is updated to
+// match docker: the running state is synced, rows without docker objects
+// are removed and orphans, docker objects with the aid labels but without
+// rows, are added if their image or solver is known. Orphans younger than
+// orphanGracePeriod are left alone, as their rows may not be saved yet.
+func Reconcile(fix bool) ([]Drift, error) {
+	// images first, so that orphaned containers of adopted images can be adopted as well
+	imageDrifts, err := reconcileImages(fix)
+	if err != nil {
+		return nil, err
+	}
+	containerDrifts, err := reconcileContainers(fix)
+	if err != nil {
+		return imageDrifts, err
+	}
+	return append(imageDrifts, containerDrifts...), nil
+}
+
+func reconcileContainers(fix bool) ([]Drift, error) {
+	ctx := context.Background()
+	cli, err := NewDockerRuntime()
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, utilities.NewError(utilities.DockerError, "cannot list containers", err)
+	}
+	rows, err := database.NewDefaultDB().Container.Query().All(ctx)
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch containers", err)
+	}
+	images, err := database.NewDefaultDB().Image.Query().All(ctx)
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch images", err)
+	}
+	imagesByUID := make(map[string]*ent.Image)
+	for _, image := range images {
+		imagesByUID[image.UID] = image
+	}
+	var drifts []Drift
+	known := make(map[string]bool)
+	for _, row := range rows {
+		var summary *types.Container
+		for idx := range summaries {
+			if strings.HasPrefix(summaries[idx].ID, row.UID) {
+				summary = &summaries[idx]
+				break
+			}
+		}
+		if summary == nil {
+			drift := Drift{Kind: DriftMissing, Entity: "container", UID: row.UID, Message: "the container does not exist in docker"}
+			if fix {
+				err = database.NewDefaultDB().Container.DeleteOne(row).Exec(ctx)
+				drift.Fixed = err == nil
+			}
+			drifts = append(drifts, drift)
+			continue
+		}
+		known[summary.ID] = true
+		running := isRunning(summary.State)
+		if running != row.Running {
+			drift := Drift{Kind: DriftState, Entity: "container", UID: row.UID, Message: "the container is " + summary.State + " in docker, running is " + strconv.FormatBool(row.Running) + " in the database"}
+			if fix {
+				update := row.Update().SetRunning(running)
+				if !running {
+					update = update.ClearHealth()
+				}
+				_, err = update.Save(ctx)
+				drift.Fixed = err == nil
+			}
+			drifts = append(drifts, drift)
+		}
+	}
+	for _, summary := range summaries {
+		if known[summary.ID] {
+			continue
+		}
+		if summary.Labels[LabelManaged] != "true" {
+			// not created by aid
+			continue
+		}
+		if isRecent(summary.Created) {
+			continue
+		}
+		image, ok := imagesByUID[shortImageID(summary.ImageID)]
+		drift := Drift{Kind: DriftOrphan, Entity: "container", UID: summary.ID[0:10], Message: "the container " + containerName(summary) + " of " + summary.Image + " is not in the database"}
+		if fix && ok {
+			_, err = database.NewDefaultDB().Container.Create().
+				SetUID(summary.ID[0:10]).
+				SetName(containerName(summary)).
+				SetPort(publishedPort(summary)).
+				SetRunning(isRunning(summary.State)).
+				SetImage(image).
+				Save(ctx)
+			drift.Fixed = err == nil
+		}
+		drifts = append(drifts, drift)
+	}
+	return drifts, nil
+}
+
+func reconcileImages(fix bool) ([]Drift, error) {
+	ctx := context.Background()
+	// rows are checked against all images, as images built by older versions have no labels
+	cli, err := NewDockerRuntime()
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := cli.ImageList(ctx, types.ImageListOptions{})
+	if err != nil {
+		return nil, utilities.NewError(utilities.DockerError, "cannot list images", err)
+	}
+	managed, err := ListImages(nil)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := database.NewDefaultDB().Image.Query().All(ctx)
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch images", err)
+	}
+	existing := make(map[string]bool)
+	for _, summary := range summaries {
+		existing[shortImageID(summary.ID)] = true
+	}
+	var drifts []Drift
+	known := make(map[string]bool)
+	for _, row := range rows {
+		if existing[row.UID] {
+			known[row.UID] = true
+			continue
+		}
+		drift := Drift{Kind: DriftMissing, Entity: "image", UID: row.UID, Message: "the image " + row.Title + " does not exist in docker"}
+		if fix {
+			err = database.NewDefaultDB().Image.DeleteOne(row).Exec(ctx)
+			drift.Fixed = err == nil
+		}
+		drifts = append(drifts, drift)
+	}
+	for _, summary := range managed {
+		uid := shortImageID(summary.ID)
+		if known[uid] || isRecent(summary.Created) {
+			continue
+		}
+		title := "aid/" + summary.Labels[LabelVendor] + "/" + summary.Labels[LabelPackage] + "/" + summary.Labels[LabelSolver]
+		drift := Drift{Kind: DriftOrphan, Entity: "image", UID: uid, Message: "the image " + title + " is not in the database"}
+		if fix {
+			drift.Fixed = adoptImage(uid, summary.Labels) == nil
+		}
+		drifts = append(drifts, drift)
+	}
+	return drifts, nil
+}
+
+// adoptImage adds the image if the solver in its labels is installed
+func adoptImage(uid string, labels map[string]string) error {
+	ctx := context.Background()
+	repo, err := database.NewDefaultDB().Repository.Query().Where(entRepository.Vendor(labels[LabelVendor]), entRepository.Name(labels[LabelPackage])).First(ctx)
+	if err != nil {
+		return utilities.NewError(utilities.DatabaseError, "cannot fetch package "+labels[LabelVendor]+"/"+labels[LabelPackage], err)
+	}
+	solver, err := repo.QuerySolvers().Where(entSolver.Name(labels[LabelSolver])).First(ctx)
+	if err != nil {
+		return utilities.NewError(utilities.DatabaseError, "cannot fetch solver "+labels[LabelSolver]+" of "+repo.Name, err)
+	}
+	title := "aid/" + repo.Vendor + "/" + repo.Name + "/" + solver.Name
+	if _, err = database.NewDefaultDB().Image.Create().SetUID(uid).SetTitle(title).SetSolver(solver).Save(ctx); err != nil {
+		return utilities.NewError(utilities.DatabaseError, "cannot save image "+title, err)
+	}
+	return nil
+}
+
+// isRecent returns true if the docker object was created within the grace period
+func isRecent(created int64) bool {
+	return time.Since(time.Unix(created, 0)) < orphanGracePeriod
+}
+
+// isRunning treats restarting containers as running, as docker does for docker start
+func isRunning(state string) bool {
+	return state == "running" || state == "restarting"
+}
+
+// shortImageID converts sha256:[digest] into the unique id used by aid
+func shortImageID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 10 {
+		return id[0:10]
+	}
+	return id
+}
+
+func containerName(summary types.Container) string {
+	if len(summary.Names) == 0 {
+		return summary.ID[0:10]
+	}
+	return strings.TrimPrefix(summary.Names[0], "/")
+}
+
+// publishedPort returns the host port of the solver port 8080
+func publishedPort(summary types.Container) string {
+	for _, port := range summary.Ports {
+		if port.PrivatePort == 8080 && port.PublicPort != 0 {
+			return strconv.Itoa(int(port.PublicPort))
+		}
+	}
+	return ""
+}
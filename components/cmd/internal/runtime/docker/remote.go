@@ -8,7 +8,6 @@ package docker
 import (
 	"context"
 	"io"
-	"os"
 
 	"github.com/autoai-org/aid/internal/utilities"
 	"github.com/docker/docker/api/types"
@@ -16,11 +15,15 @@ import (
 
 // Pull will pull an exisiting package
 func Pull(imageName string) error {
-	reader, err := Client.ImagePull(context.Background(), imageName, types.ImagePullOptions{})
+	cli, err := NewDockerRuntime()
 	if err != nil {
-		utilities.ReportError(err, "Cannot pull image "+imageName)
 		return err
 	}
-	io.Copy(os.Stdout, reader)
+	reader, err := cli.ImagePull(context.Background(), imageName, types.ImagePullOptions{})
+	if err != nil {
+		return utilities.NewError(utilities.DockerError, "cannot pull image "+imageName, err)
+	}
+	defer reader.Close()
+	io.Copy(utilities.LogWriter, reader)
 	return nil
 }
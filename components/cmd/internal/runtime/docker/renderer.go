@@ -11,46 +11,53 @@ import (
 	"path/filepath"
 	"strings"
 
-	ent "github.com/autoai-org/aid/ent/generated"
 	"github.com/autoai-org/aid/internal/configuration"
 	"github.com/autoai-org/aid/internal/utilities"
 	"github.com/flosch/pongo2"
 )
 
 //getTpl returns the template string
-func getTpl(filename string) string {
-	data, err := Asset("internal/assets/" + filename + ".sql")
-	utilities.ReportError(err, "canno read file "+filename)
-	return string(data)
+func getTpl(filename string) (string, error) {
+	data, err := Asset("internal/assets/" + filename + ".tpl")
+	if err != nil {
+		return "", utilities.NewError(utilities.UnknownError, "cannot read template "+filename, err)
+	}
+	return string(data), nil
 }
 
 // GenerateDockerFiles returns a DockerFile string that could be used to build image.
-func GenerateDockerFiles(baseFilePath string) {
-	configFile := filepath.Join(baseFilePath, "aid.toml")
-	tomlString, err := utilities.ReadFileContent(configFile)
+func GenerateDockerFiles(baseFilePath string) error {
+	manifest, err := configuration.ReadManifest(baseFilePath)
 	if err != nil {
-		utilities.ReportError(err, "cannot open file "+configFile)
+		return err
 	}
-	packageInfo := configuration.LoadPackageFromConfig(tomlString)
-	for _, solver := range packageInfo.Solvers {
-		RenderDockerfile(solver.Name, baseFilePath)
+	for _, solver := range manifest.Solvers {
+		if err := RenderDockerfile(solver.Name, baseFilePath); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // RenderDockerfile returns the final dockerfile
-func RenderDockerfile(solvername string, targetFilePath string) {
-	tpl, err := pongo2.FromString(getTpl("dockerfile"))
-	utilities.ReportError(err, "Cannot render dockerfile")
+func RenderDockerfile(solvername string, targetFilePath string) error {
+	tplString, err := getTpl("dockerfile")
+	if err != nil {
+		return err
+	}
+	tpl, err := pongo2.FromString(tplString)
+	if err != nil {
+		return utilities.NewError(utilities.UnknownError, "cannot parse dockerfile template", err)
+	}
 	filename := filepath.Join(targetFilePath, "docker_"+solvername)
 	setupFilePath := filepath.Join(targetFilePath, "setup.sh")
 	var setupCommands string = ""
 	if utilities.IsExists(setupFilePath) {
 		f, err := os.Open(setupFilePath)
-		defer f.Close()
 		if err != nil {
-			utilities.ReportError(err, "Cannot open file "+setupFilePath)
-			setupCommands = "echo An error occured in parsing setup file"
+			return utilities.NewError(utilities.PackageError, "cannot open file "+setupFilePath, err)
 		}
+		defer f.Close()
 		scanner := bufio.NewScanner(f)
 		for scanner.Scan() {
 			if setupCommands == "" {
@@ -66,11 +73,10 @@ func RenderDockerfile(solvername string, targetFilePath string) {
 	var prepipCommands string = ""
 	if utilities.IsExists(prepipFilePath) {
 		f, err := os.Open(prepipFilePath)
-		defer f.Close()
 		if err != nil {
-			utilities.ReportError(err, "Cannot open file "+prepipFilePath)
-			prepipCommands = "echo An error occured in parsing setup file"
+			return utilities.NewError(utilities.PackageError, "cannot open file "+prepipFilePath, err)
 		}
+		defer f.Close()
 		scanner := bufio.NewScanner(f)
 		for scanner.Scan() {
 			if prepipCommands == "" {
@@ -83,19 +89,39 @@ func RenderDockerfile(solvername string, targetFilePath string) {
 		prepipCommands = "echo There is no command for extra installation"
 	}
 	out, err := tpl.Execute(pongo2.Context{"Solvername": solvername, "Setup": setupCommands, "PrePIP": prepipCommands})
-	utilities.WriteContentToFile(filename, out)
+	if err != nil {
+		return utilities.NewError(utilities.UnknownError, "cannot render dockerfile for "+solvername, err)
+	}
+	if err := utilities.WriteContentToFile(filename, out); err != nil {
+		return utilities.NewError(utilities.PackageError, "cannot write file "+filename, err)
+	}
+	return nil
 }
 
 // RenderRunnerTpl returns the final runner file
-func RenderRunnerTpl(tempFilePath string, mySolvers []ent.Solver) {
-	tpl, err := pongo2.FromString(getTpl("runner"))
-	utilities.ReportError(err, "Cannot read template file")
+func RenderRunnerTpl(tempFilePath string, mySolvers []configuration.SolverManifest) error {
+	tplString, err := getTpl("runner")
+	if err != nil {
+		return err
+	}
+	tpl, err := pongo2.FromString(tplString)
+	if err != nil {
+		return utilities.NewError(utilities.UnknownError, "cannot parse runner template", err)
+	}
 	for _, solver := range mySolvers {
 		filename := "runner_" + solver.Name + ".py"
 		fileFullPath := filepath.Join(tempFilePath, filename)
 		tplContext := strings.Split(solver.Class, "/")
+		if len(tplContext) != 3 {
+			return utilities.NewError(utilities.PackageError, "class of solver "+solver.Name+" should be [package]/[file]/[Class], got "+solver.Class, nil)
+		}
 		out, err := tpl.Execute(pongo2.Context{"Package": tplContext[0], "Filename": tplContext[1], "Classname": tplContext[2]})
-		utilities.ReportError(err, "Failed to generate running file.")
-		utilities.WriteContentToFile(fileFullPath, out)
+		if err != nil {
+			return utilities.NewError(utilities.UnknownError, "failed to generate running file", err)
+		}
+		if err := utilities.WriteContentToFile(fileFullPath, out); err != nil {
+			return utilities.NewError(utilities.PackageError, "cannot write file "+fileFullPath, err)
+		}
 	}
+	return nil
 }
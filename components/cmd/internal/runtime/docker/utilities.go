@@ -2,6 +2,7 @@ package docker
 
 import (
 	"io"
+	"sync"
 
 	"github.com/autoai-org/aid/internal/utilities"
 	"github.com/docker/docker/client"
@@ -16,14 +17,24 @@ type BuildLog struct {
 // Client is the basic class for manage docker
 var Client *client.Client
 
-// NewDockerRuntime returns a DockerRuntime Instance
-func NewDockerRuntime() *client.Client {
-	if Client != nil {
-		return Client
-	}
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	utilities.ReportError(err, "Cannot Create New Docker Runtime")
-	return cli
+var (
+	clientErr  error
+	clientOnce sync.Once
+)
+
+// NewDockerRuntime returns the docker client shared by all callers, it is
+// created from the environment, e.g. $DOCKER_HOST, on the first call
+func NewDockerRuntime() (*client.Client, error) {
+	clientOnce.Do(func() {
+		if Client != nil {
+			return
+		}
+		Client, clientErr = client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		if clientErr != nil {
+			clientErr = utilities.NewError(utilities.DockerError, "cannot create docker client", clientErr)
+		}
+	})
+	return Client, clientErr
 }
 
 func getBuildCtx(dockerPath string) io.Reader {
@@ -0,0 +1,273 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/autoai-org/aid/internal/system"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// IndexFile is the name of the index of every package in the registry,
+// it is stored at [registry]/[vendor]/[name]/index.json
+const IndexFile = "index.json"
+
+// packageRefPattern matches vendor/name and vendor/name@version
+var packageRefPattern = regexp.MustCompile(`^([\w.-]+)/([\w.-]+)(?:@([\w.+-]+))?$`)
+
+// Index lists all published versions of a package
+type Index struct {
+	Vendor   string    `json:"vendor"`
+	Name     string    `json:"name"`
+	Latest   string    `json:"latest"`
+	Versions []Release `json:"versions"`
+}
+
+// commitPattern matches a full git commit hash
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Release is a published version of a package. The source is either a
+// tarball (.tar.gz), relative to the index or absolute, with its sha256,
+// or a git url with the full hash of the commit as ref.
+type Release struct {
+	Version string `json:"version"`
+	Tarball string `json:"tarball,omitempty"`
+	Git     string `json:"git,omitempty"`
+	Ref     string `json:"ref,omitempty"`
+	Sha256  string `json:"sha256,omitempty"`
+}
+
+// PackageRef is a parsed vendor/name@version reference
+type PackageRef struct {
+	Vendor  string
+	Name    string
+	Version string
+}
+
+// String returns vendor/name@version
+func (ref PackageRef) String() string {
+	if ref.Version == "" {
+		return ref.Vendor + "/" + ref.Name
+	}
+	return ref.Vendor + "/" + ref.Name + "@" + ref.Version
+}
+
+// ParsePackageRef parses vendor/name@version, the version is optional.
+// Relative paths such as ./name and ../name are not package references.
+func ParsePackageRef(reference string) (PackageRef, bool) {
+	matches := packageRefPattern.FindStringSubmatch(reference)
+	if matches == nil || isDotSegment(matches[1]) || isDotSegment(matches[2]) {
+		return PackageRef{}, false
+	}
+	return PackageRef{Vendor: matches[1], Name: matches[2], Version: matches[3]}, true
+}
+
+func isDotSegment(segment string) bool {
+	return segment == "." || segment == ".."
+}
+
+// Registry is a package registry, served over http(s) or from the local file system
+type Registry struct {
+	baseURL string
+}
+
+// New returns the registry at baseURL, which is either a http(s) url,
+// a file:// url or a local path.
+func New(baseURL string) *Registry {
+	return &Registry{baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Resolve finds the release of the package, the latest one if no version is given
+func (r *Registry) Resolve(ref PackageRef) (*Release, error) {
+	index, err := r.index(ref)
+	if err != nil {
+		return nil, err
+	}
+	version := ref.Version
+	if version == "" || version == "latest" {
+		version = index.Latest
+	}
+	for _, release := range index.Versions {
+		if release.Version == version {
+			return r.locate(ref, release)
+		}
+	}
+	return nil, utilities.NewError(utilities.RequestError, "cannot find version "+version+" of "+ref.Vendor+"/"+ref.Name, nil)
+}
+
+// ResolveConstraint finds the highest release of the package that satisfies the constraint
+func (r *Registry) ResolveConstraint(ref PackageRef, constraint *Constraint) (*Release, error) {
+	index, err := r.index(ref)
+	if err != nil {
+		return nil, err
+	}
+	var best *Release
+	var bestVersion Version
+	for idx, release := range index.Versions {
+		version, err := ParseVersion(release.Version)
+		if err != nil || !constraint.Check(release.Version) {
+			continue
+		}
+		if best == nil || version.Compare(bestVersion) > 0 {
+			best, bestVersion = &index.Versions[idx], version
+		}
+	}
+	if best == nil {
+		return nil, utilities.NewError(utilities.RequestError, "no version of "+ref.Vendor+"/"+ref.Name+" satisfies the constraint", nil)
+	}
+	return r.locate(ref, *best)
+}
+
+// index reads the index of the package
+func (r *Registry) index(ref PackageRef) (*Index, error) {
+	indexURL := r.join(ref.Vendor, ref.Name, IndexFile)
+	reader, err := open(indexURL)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	var index Index
+	if err := json.NewDecoder(reader).Decode(&index); err != nil {
+		return nil, utilities.NewError(utilities.PackageError, "cannot parse "+indexURL, err)
+	}
+	return &index, nil
+}
+
+// locate makes relative tarballs of the release absolute, releases that
+// cannot be verified are rejected
+func (r *Registry) locate(ref PackageRef, release Release) (*Release, error) {
+	if err := release.verifiable(); err != nil {
+		return nil, utilities.NewError(utilities.PackageError, "cannot install "+ref.Vendor+"/"+ref.Name+"@"+release.Version, err)
+	}
+	if release.Tarball != "" && !isAbsolute(release.Tarball) {
+		release.Tarball = r.join(ref.Vendor, ref.Name, release.Tarball)
+	}
+	return &release, nil
+}
+
+// verifiable returns an error if the source of the release cannot be
+// verified, tarballs need a sha256 and git releases a commit hash as ref
+func (release *Release) verifiable() error {
+	switch {
+	case release.Git != "":
+		if !commitPattern.MatchString(release.Ref) {
+			return errors.New("the git release is not pinned to a full commit hash, got ref " + strconv.Quote(release.Ref))
+		}
+	case release.Tarball != "":
+		if _, err := utilities.NormalizeSha256(release.Sha256); err != nil {
+			return errors.New("the tarball has no valid sha256")
+		}
+	default:
+		return errors.New("the release has neither a tarball nor a git url")
+	}
+	return nil
+}
+
+// VerifyCommit returns an error if the commit cloned for a git release is
+// not the one the release is pinned to
+func (release *Release) VerifyCommit(commit string) error {
+	if commit != release.Ref {
+		return utilities.NewError(utilities.PackageError, "release "+release.Version+" is pinned to "+release.Ref+", but "+release.Git+" resolved to "+commit, nil)
+	}
+	return nil
+}
+
+// Fetch downloads the tarball of the release, verifies its checksum
+// and extracts it into targetFolder
+func (r *Registry) Fetch(release *Release, targetFolder string) error {
+	if release.Tarball == "" {
+		return utilities.NewError(utilities.PackageError, "release "+release.Version+" has no tarball", nil)
+	}
+	digest, err := utilities.NormalizeSha256(release.Sha256)
+	if err != nil {
+		return utilities.NewError(utilities.PackageError, "release "+release.Version+" has no valid sha256", err)
+	}
+	reader, err := open(release.Tarball)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	tempFile, err := ioutil.TempFile(utilities.GetFolder("temp"), "registry-*.tar.gz")
+	if err != nil {
+		return utilities.NewError(utilities.UnknownError, "cannot create temporary file", err)
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+	utilities.Formatter.Info("Downloading " + release.Tarball)
+	if _, err := io.Copy(tempFile, reader); err != nil {
+		return utilities.NewError(utilities.NetworkError, "cannot download "+release.Tarball, err)
+	}
+	if err := utilities.VerifySha256(tempFile.Name(), digest); err != nil {
+		return utilities.NewError(utilities.PackageError, "checksum mismatch for "+release.Tarball, err)
+	}
+	if _, err := tempFile.Seek(0, io.SeekStart); err != nil {
+		return utilities.NewError(utilities.UnknownError, "cannot read "+tempFile.Name(), err)
+	}
+	if err := utilities.ExtractTarGz(tempFile, targetFolder); err != nil {
+		return utilities.NewError(utilities.PackageError, "cannot extract "+release.Tarball, err)
+	}
+	return nil
+}
+
+func (r *Registry) join(elem ...string) string {
+	return r.baseURL + "/" + path.Join(elem...)
+}
+
+func isAbsolute(location string) bool {
+	return strings.Contains(location, "://") || filepath.IsAbs(location)
+}
+
+// open reads a http(s) url, a file:// url or a local path
+func open(location string) (io.ReadCloser, error) {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		resp, err := http.Get(location)
+		if err != nil {
+			return nil, utilities.NewError(utilities.NetworkError, "cannot fetch "+location, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, utilities.NewError(utilities.NetworkError, "cannot fetch "+location+": "+resp.Status, nil)
+		}
+		return resp.Body, nil
+	}
+	localPath := location
+	if strings.HasPrefix(location, "file://") {
+		parsed, err := url.Parse(location)
+		if err != nil {
+			return nil, utilities.NewError(utilities.RequestError, "invalid url "+location, err)
+		}
+		localPath = parsed.Path
+	}
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, utilities.NewError(utilities.PackageError, "cannot open "+localPath, err)
+	}
+	return file, nil
+}
+
+// Default returns the registry set by $AID_REGISTRY or the system config,
+// or the local registry under ~/.autoai/aid/registry if none is set
+func Default() *Registry {
+	if baseURL := os.Getenv("AID_REGISTRY"); baseURL != "" {
+		return New(baseURL)
+	}
+	if baseURL := system.NewDefaultConfig().Registry; baseURL != "" {
+		return New(baseURL)
+	}
+	return New(utilities.GetFolder(utilities.REGISTRYFOLDER))
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package registry
+
+import "testing"
+
+func TestParsePackageRef(t *testing.T) {
+	tests := []struct {
+		reference string
+		want      PackageRef
+		ok        bool
+	}{
+		{reference: "vendor/name", want: PackageRef{Vendor: "vendor", Name: "name"}, ok: true},
+		{reference: "vendor/name@1.2.3", want: PackageRef{Vendor: "vendor", Name: "name", Version: "1.2.3"}, ok: true},
+		{reference: "my.vendor/my-name@1.0.0-rc.1+build", want: PackageRef{Vendor: "my.vendor", Name: "my-name", Version: "1.0.0-rc.1+build"}, ok: true},
+		{reference: "./mypkg"},
+		{reference: "../mypkg"},
+		{reference: "vendor/.."},
+		{reference: "/mypkg"},
+		{reference: "/vendor/name"},
+		{reference: "vendor/name/solver"},
+		{reference: "https://github.com/vendor/name"},
+		{reference: "git@github.com:vendor/name"},
+		{reference: "vendor/name@"},
+	}
+	for _, test := range tests {
+		got, ok := ParsePackageRef(test.reference)
+		if ok != test.ok || got != test.want {
+			t.Errorf("ParsePackageRef(%q) = %+v, %v, want %+v, %v", test.reference, got, ok, test.want, test.ok)
+		}
+	}
+}
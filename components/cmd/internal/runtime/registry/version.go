@@ -0,0 +1,216 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package registry
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// Version is a parsed [major].[minor].[patch][-prerelease] version,
+// missing parts are 0 and the v prefix is optional
+type Version struct {
+	Major, Minor, Patch int
+	Prerelease          string
+}
+
+// ParseVersion parses versions like 1, 1.2, v1.2.3 and 1.2.3-rc.1
+func ParseVersion(version string) (Version, error) {
+	var parsed Version
+	core := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if idx := strings.IndexAny(core, "-+"); idx >= 0 {
+		if core[idx] == '-' {
+			parsed.Prerelease = strings.SplitN(core[idx+1:], "+", 2)[0]
+			if !validPrerelease(parsed.Prerelease) {
+				return parsed, utilities.NewError(utilities.RequestError, "invalid version "+version, nil)
+			}
+		}
+		core = core[:idx]
+	}
+	parts := strings.Split(core, ".")
+	if core == "" || len(parts) > 3 {
+		return parsed, utilities.NewError(utilities.RequestError, "invalid version "+version, nil)
+	}
+	numbers := []*int{&parsed.Major, &parsed.Minor, &parsed.Patch}
+	for idx, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return parsed, utilities.NewError(utilities.RequestError, "invalid version "+version, err)
+		}
+		*numbers[idx] = number
+	}
+	return parsed, nil
+}
+
+// prereleasePattern matches a dot-separated pre-release identifier
+var prereleasePattern = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+
+// validPrerelease returns true if the pre-release is a non-empty list of
+// dot-separated identifiers
+func validPrerelease(prerelease string) bool {
+	for _, identifier := range strings.Split(prerelease, ".") {
+		if !prereleasePattern.MatchString(identifier) {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare returns -1, 0 or 1 if v is lower than, equal to or higher than other.
+// Pre-releases are lower than their release and are compared as in semver,
+// see comparePrerelease.
+func (v Version) Compare(other Version) int {
+	for _, diff := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if diff < 0 {
+			return -1
+		}
+		if diff > 0 {
+			return 1
+		}
+	}
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// comparePrerelease compares the dot-separated identifiers from left to right,
+// numeric ones numerically and lower than alphanumeric ones. A shorter list of
+// otherwise equal identifiers is lower, e.g. rc < rc.1 < rc.2 < rc.10 < rc.a.
+func comparePrerelease(prerelease, other string) int {
+	identifiers, others := strings.Split(prerelease, "."), strings.Split(other, ".")
+	for idx := 0; idx < len(identifiers) && idx < len(others); idx++ {
+		number, numErr := strconv.Atoi(identifiers[idx])
+		otherNumber, otherErr := strconv.Atoi(others[idx])
+		switch {
+		case numErr == nil && otherErr == nil:
+			if number != otherNumber {
+				return compareInts(number, otherNumber)
+			}
+		case numErr == nil:
+			return -1
+		case otherErr == nil:
+			return 1
+		case identifiers[idx] != others[idx]:
+			return strings.Compare(identifiers[idx], others[idx])
+		}
+	}
+	return compareInts(len(identifiers), len(others))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// constraintTerm is a single comparison, e.g. >=1.2.0
+type constraintTerm struct {
+	operator string
+	version  Version
+}
+
+// Constraint is a list of terms that must all be satisfied, e.g.
+// ">=1.2.0, <2.0.0". Supported operators are =, !=, >, >=, <, <=,
+// ^ (same major version) and ~ (same minor version). An empty
+// constraint or * matches every release. Pre-releases only match if a
+// term names a pre-release of the same [major].[minor].[patch], e.g.
+// ">=2.0.0-rc.1" matches 2.0.0-rc.2 but not 2.1.0-rc.1.
+type Constraint struct {
+	terms []constraintTerm
+}
+
+// operators are ordered so that longer ones are matched first
+var operators = []string{">=", "<=", "!=", ">", "<", "=", "^", "~"}
+
+// ParseConstraint parses a comma separated list of version constraints
+func ParseConstraint(constraint string) (*Constraint, error) {
+	parsed := &Constraint{}
+	if strings.TrimSpace(constraint) == "" || strings.TrimSpace(constraint) == "*" {
+		return parsed, nil
+	}
+	for _, term := range strings.Split(constraint, ",") {
+		term = strings.TrimSpace(term)
+		operator := "="
+		for _, candidate := range operators {
+			if strings.HasPrefix(term, candidate) {
+				operator = candidate
+				term = strings.TrimSpace(strings.TrimPrefix(term, candidate))
+				break
+			}
+		}
+		version, err := ParseVersion(term)
+		if err != nil {
+			return nil, utilities.NewError(utilities.RequestError, "invalid version constraint "+constraint, err)
+		}
+		parsed.terms = append(parsed.terms, constraintTerm{operator: operator, version: version})
+	}
+	return parsed, nil
+}
+
+// Check returns true if the version satisfies all terms of the constraint
+func (c *Constraint) Check(version string) bool {
+	parsed, err := ParseVersion(version)
+	if err != nil {
+		return false
+	}
+	if parsed.Prerelease != "" && !c.allowsPrerelease(parsed) {
+		return false
+	}
+	for _, term := range c.terms {
+		if !term.check(parsed) {
+			return false
+		}
+	}
+	return true
+}
+
+// allowsPrerelease returns true if a term names a pre-release of the same
+// [major].[minor].[patch] as the version
+func (c *Constraint) allowsPrerelease(version Version) bool {
+	for _, term := range c.terms {
+		if term.version.Prerelease != "" && term.version.Major == version.Major &&
+			term.version.Minor == version.Minor && term.version.Patch == version.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+func (term constraintTerm) check(version Version) bool {
+	result := version.Compare(term.version)
+	switch term.operator {
+	case "=":
+		return result == 0
+	case "!=":
+		return result != 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case "^":
+		return result >= 0 && version.Major == term.version.Major
+	case "~":
+		return result >= 0 && version.Major == term.version.Major && version.Minor == term.version.Minor
+	}
+	return false
+}
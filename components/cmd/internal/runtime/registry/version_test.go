@@ -0,0 +1,163 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package registry
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    Version
+		wantErr bool
+	}{
+		{version: "1", want: Version{Major: 1}},
+		{version: "1.2", want: Version{Major: 1, Minor: 2}},
+		{version: "v1.2.3", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{version: " 1.2.3 ", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{version: "1.2.3-rc.1", want: Version{Major: 1, Minor: 2, Patch: 3, Prerelease: "rc.1"}},
+		{version: "1.2.3-rc.1+build.5", want: Version{Major: 1, Minor: 2, Patch: 3, Prerelease: "rc.1"}},
+		{version: "1.2.3+build-5", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{version: "", wantErr: true},
+		{version: "v", wantErr: true},
+		{version: "1.2.3.4", wantErr: true},
+		{version: "1.x", wantErr: true},
+		{version: "1.-2", wantErr: true},
+		{version: "1.2.3-", wantErr: true},
+		{version: "1.2.3-rc..1", wantErr: true},
+		{version: "1.2.3-rc_1", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseVersion(test.version)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseVersion(%q) error = %v, want error %v", test.version, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && got != test.want {
+			t.Errorf("ParseVersion(%q) = %+v, want %+v", test.version, got, test.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.2.3", b: "1.2.3", want: 0},
+		{a: "1.2", b: "1.2.0", want: 0},
+		{a: "1.2.3", b: "1.2.4", want: -1},
+		{a: "1.10.0", b: "1.9.0", want: 1},
+		{a: "2.0.0", b: "1.99.99", want: 1},
+		{a: "1.0.0-rc.1", b: "1.0.0", want: -1},
+		{a: "1.0.0", b: "1.0.0-rc.1", want: 1},
+		{a: "1.0.0-rc.10", b: "1.0.0-rc.9", want: 1},
+		{a: "1.0.0-rc", b: "1.0.0-rc.1", want: -1},
+		{a: "1.0.0-rc.1", b: "1.0.0-rc.a", want: -1},
+		{a: "1.0.0-alpha", b: "1.0.0-beta", want: -1},
+		{a: "1.0.0-beta.2", b: "1.0.0-beta.11", want: -1},
+		{a: "1.0.0-rc.1+build.1", b: "1.0.0-rc.1+build.2", want: 0},
+	}
+	for _, test := range tests {
+		a, err := ParseVersion(test.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseVersion(test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.Compare(b); got != test.want {
+			t.Errorf("%s.Compare(%s) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       []constraintTerm
+		wantErr    bool
+	}{
+		{constraint: ""},
+		{constraint: " * "},
+		{constraint: "1.2.3", want: []constraintTerm{{operator: "=", version: Version{Major: 1, Minor: 2, Patch: 3}}}},
+		{constraint: ">= 1.2, <2", want: []constraintTerm{
+			{operator: ">=", version: Version{Major: 1, Minor: 2}},
+			{operator: "<", version: Version{Major: 2}},
+		}},
+		{constraint: "^1.2", want: []constraintTerm{{operator: "^", version: Version{Major: 1, Minor: 2}}}},
+		{constraint: "~1.2.3", want: []constraintTerm{{operator: "~", version: Version{Major: 1, Minor: 2, Patch: 3}}}},
+		{constraint: "!=2.0.0-rc.1", want: []constraintTerm{{operator: "!=", version: Version{Major: 2, Prerelease: "rc.1"}}}},
+		{constraint: ">=", wantErr: true},
+		{constraint: "1.2,", wantErr: true},
+		{constraint: "=>1.2", wantErr: true},
+		{constraint: "latest", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseConstraint(test.constraint)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseConstraint(%q) error = %v, want error %v", test.constraint, err, test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+		if len(got.terms) != len(test.want) {
+			t.Errorf("ParseConstraint(%q) = %+v, want %+v", test.constraint, got.terms, test.want)
+			continue
+		}
+		for idx := range test.want {
+			if got.terms[idx] != test.want[idx] {
+				t.Errorf("ParseConstraint(%q) = %+v, want %+v", test.constraint, got.terms, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{constraint: "", version: "1.2.3", want: true},
+		{constraint: "*", version: "0.0.1", want: true},
+		{constraint: "", version: "1.2.3-rc.1", want: false},
+		{constraint: "1.2", version: "1.2.0", want: true},
+		{constraint: "1.2", version: "1.2.1", want: false},
+		{constraint: "!=1.2.0", version: "1.2.1", want: true},
+		{constraint: ">=1.2, <2", version: "1.2.0", want: true},
+		{constraint: ">=1.2, <2", version: "1.9.9", want: true},
+		{constraint: ">=1.2, <2", version: "2.0.0", want: false},
+		{constraint: ">=1.2, <2", version: "2.0.0-rc.1", want: false},
+		{constraint: ">=1.2, <2", version: "1.5.0-rc.1", want: false},
+		{constraint: ">1.2", version: "1.2.0", want: false},
+		{constraint: "<=1.2", version: "1.2.0", want: true},
+		{constraint: "^1.2", version: "1.9.0", want: true},
+		{constraint: "^1.2", version: "1.1.0", want: false},
+		{constraint: "^1.2", version: "2.0.0", want: false},
+		{constraint: "~1.2.3", version: "1.2.9", want: true},
+		{constraint: "~1.2.3", version: "1.3.0", want: false},
+		{constraint: ">=2.0.0-rc.1", version: "2.0.0-rc.2", want: true},
+		{constraint: ">=2.0.0-rc.1", version: "2.0.0-rc.10", want: true},
+		{constraint: ">=2.0.0-rc.2", version: "2.0.0-rc.1", want: false},
+		{constraint: ">=2.0.0-rc.1", version: "2.0.0", want: true},
+		{constraint: ">=2.0.0-rc.1", version: "2.1.0-rc.1", want: false},
+		{constraint: "^2.0.0-rc.1", version: "2.0.1", want: true},
+		{constraint: "=2.0.0-rc.1", version: "2.0.0-rc.1", want: true},
+		{constraint: ">=1.0", version: "not-a-version", want: false},
+	}
+	for _, test := range tests {
+		constraint, err := ParseConstraint(test.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q) error = %v", test.constraint, err)
+		}
+		if got := constraint.Check(test.version); got != test.want {
+			t.Errorf("ParseConstraint(%q).Check(%q) = %v, want %v", test.constraint, test.version, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package requests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/autoai-org/aid/internal/system"
+	"github.com/autoai-org/aid/internal/utilities"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// defaultSSHKeys are tried in order if neither a key file nor an ssh agent is available
+var defaultSSHKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// authFor returns the credentials to access the remote, or nil if there
+// are none. Environment variables take precedence over the system config:
+// $AID_GIT_TOKEN, $AID_GIT_TOKEN_HOST, $AID_GIT_USERNAME, $AID_GIT_SSH_KEY
+// and $AID_GIT_SSH_PASSPHRASE. Tokens are only sent over https to the hosts
+// they are configured for, as remotes may come from packages or registries.
+func authFor(gitURL *GitURL) (transport.AuthMethod, error) {
+	gitConfig := system.NewDefaultConfig().Git
+	switch gitURL.Scheme {
+	case "https":
+		token := gitConfig.Tokens[gitURL.Host]
+		tokenHost := utilities.FirstNonEmpty(os.Getenv("AID_GIT_TOKEN_HOST"), gitConfig.TokenHost)
+		if token == "" && tokenHost != "" && strings.EqualFold(gitURL.Host, tokenHost) {
+			token = utilities.FirstNonEmpty(os.Getenv("AID_GIT_TOKEN"), gitConfig.Token)
+		}
+		if token == "" {
+			return nil, nil
+		}
+		// git hosts accept access tokens as password, the username is only checked to be non-empty
+		username := utilities.FirstNonEmpty(os.Getenv("AID_GIT_USERNAME"), gitConfig.Username, "git")
+		return &http.BasicAuth{Username: username, Password: token}, nil
+	case "ssh":
+		user := utilities.FirstNonEmpty(gitURL.User, "git")
+		passphrase := utilities.FirstNonEmpty(os.Getenv("AID_GIT_SSH_PASSPHRASE"), gitConfig.SSHPassphrase)
+		if keyFile := utilities.FirstNonEmpty(os.Getenv("AID_GIT_SSH_KEY"), gitConfig.SSHKey); keyFile != "" {
+			auth, err := ssh.NewPublicKeysFromFile(user, keyFile, passphrase)
+			if err != nil {
+				return nil, utilities.NewError(utilities.RequestError, "cannot load ssh key "+keyFile, err)
+			}
+			return auth, nil
+		}
+		if os.Getenv("SSH_AUTH_SOCK") != "" {
+			auth, err := ssh.NewSSHAgentAuth(user)
+			if err != nil {
+				return nil, utilities.NewError(utilities.RequestError, "cannot connect to ssh agent", err)
+			}
+			return auth, nil
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		for _, keyName := range defaultSSHKeys {
+			keyFile := filepath.Join(home, ".ssh", keyName)
+			if !utilities.IsFileExists(keyFile) {
+				continue
+			}
+			auth, err := ssh.NewPublicKeysFromFile(user, keyFile, passphrase)
+			if err != nil {
+				return nil, utilities.NewError(utilities.RequestError, "cannot load ssh key "+keyFile, err)
+			}
+			return auth, nil
+		}
+	}
+	return nil, nil
+}
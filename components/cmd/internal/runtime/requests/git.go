@@ -6,8 +6,17 @@
 package requests
 
 import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
 	"github.com/autoai-org/aid/internal/utilities"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
 )
 
 var logger = utilities.NewDefaultLogger()
@@ -26,13 +35,148 @@ func NewGitClient() *GitClient {
 	return defaultGitClient
 }
 
+// SplitRef splits [remote address]@[ref] into the address and the ref.
+// The ref is after the first @ in the repository path, so that refs may
+// contain slashes, e.g. feature/x, and the user of addresses like
+// git@host:name or https://user@host/vendor/name is not treated as a ref.
+func SplitRef(remoteURL string) (string, string) {
+	pathStart := 0
+	if scheme := strings.Index(remoteURL, "://"); scheme >= 0 {
+		slash := strings.Index(remoteURL[scheme+3:], "/")
+		if slash < 0 {
+			return remoteURL, ""
+		}
+		pathStart = scheme + 3 + slash
+	} else if !IsLocalPath(remoteURL) {
+		// [user@]host:path
+		if colon := strings.Index(remoteURL, ":"); colon >= 0 {
+			pathStart = colon
+		}
+	}
+	at := strings.Index(remoteURL[pathStart:], "@")
+	if at < 0 {
+		return remoteURL, ""
+	}
+	return remoteURL[:pathStart+at], remoteURL[pathStart+at+1:]
+}
+
 // Clone downloads remote contents from remoteURL to targetFolder
 func (gitclient *GitClient) Clone(remoteURL string, targetFolder string) error {
-	utilities.Formatter.Info("Cloning from " + remoteURL + " into " + targetFolder)
-	_, err := git.PlainClone(targetFolder, false, &git.CloneOptions{
-		URL:   remoteURL,
-		Depth: 1,
-	})
-	utilities.ReportError(err, "Cannot Clone from "+remoteURL)
+	return gitclient.CloneContext(context.Background(), remoteURL, targetFolder)
+}
+
+// CloneContext is the same as Clone, but the clone is aborted once ctx is cancelled
+func (gitclient *GitClient) CloneContext(ctx context.Context, remoteURL string, targetFolder string) error {
+	_, err := gitclient.CloneRef(ctx, remoteURL, targetFolder, "")
 	return err
 }
+
+// CloneRef downloads the branch, tag or commit ref of remoteURL to targetFolder
+// and returns the hash of the checked out commit. The default branch is used
+// if ref is empty.
+func (gitclient *GitClient) CloneRef(ctx context.Context, remoteURL string, targetFolder string, ref string) (string, error) {
+	auth, err := gitclient.auth(remoteURL)
+	if err != nil {
+		return "", err
+	}
+	if ref == "" {
+		utilities.Formatter.Info("Cloning from " + remoteURL + " into " + targetFolder)
+		repo, err := git.PlainCloneContext(ctx, targetFolder, false, &git.CloneOptions{
+			URL:   remoteURL,
+			Auth:  auth,
+			Depth: 1,
+		})
+		if err != nil {
+			return "", utilities.NewError(utilities.NetworkError, "cannot clone from "+remoteURL, err)
+		}
+		return headHash(repo)
+	}
+	utilities.Formatter.Info("Cloning " + ref + " from " + remoteURL + " into " + targetFolder)
+	for _, referenceName := range []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)} {
+		repo, err := git.PlainCloneContext(ctx, targetFolder, false, &git.CloneOptions{
+			URL:           remoteURL,
+			Auth:          auth,
+			ReferenceName: referenceName,
+			SingleBranch:  true,
+			Depth:         1,
+		})
+		if err == nil {
+			return headHash(repo)
+		}
+		os.RemoveAll(targetFolder)
+		if !errors.Is(err, git.NoMatchingRefSpecError{}) && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return "", utilities.NewError(utilities.NetworkError, "cannot clone "+ref+" from "+remoteURL, err)
+		}
+	}
+	// the ref is neither a branch nor a tag, it should be a commit,
+	// which requires the full history
+	repo, err := git.PlainCloneContext(ctx, targetFolder, false, &git.CloneOptions{
+		URL:  remoteURL,
+		Auth: auth,
+	})
+	if err != nil {
+		return "", utilities.NewError(utilities.NetworkError, "cannot clone from "+remoteURL, err)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return "", utilities.NewError(utilities.RequestError, "cannot find "+ref+" in "+remoteURL, err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", utilities.NewError(utilities.PackageError, "cannot open the worktree of "+targetFolder, err)
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: *hash}); err != nil {
+		return "", utilities.NewError(utilities.PackageError, "cannot checkout "+ref, err)
+	}
+	return hash.String(), nil
+}
+
+// RemoteBranchHash returns the latest commit of the branch of remoteURL, or
+// of the default branch if branch is empty. The returned bool is false if
+// there is no such branch, e.g. branch is a tag or a commit.
+func (gitclient *GitClient) RemoteBranchHash(remoteURL string, branch string) (string, bool, error) {
+	auth, err := gitclient.auth(remoteURL)
+	if err != nil {
+		return "", false, err
+	}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{remoteURL},
+	})
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return "", false, utilities.NewError(utilities.NetworkError, "cannot list references of "+remoteURL, err)
+	}
+	target := plumbing.NewBranchReferenceName(branch)
+	if branch == "" {
+		target = plumbing.HEAD
+	}
+	for _, reference := range refs {
+		if reference.Name() == target && reference.Type() == plumbing.SymbolicReference {
+			target = reference.Target()
+		}
+	}
+	for _, reference := range refs {
+		if reference.Name() == target && reference.Type() == plumbing.HashReference {
+			return reference.Hash().String(), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// auth returns the credentials for remoteURL, see authFor
+func (gitclient *GitClient) auth(remoteURL string) (transport.AuthMethod, error) {
+	gitURL, err := ParseGitURL(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+	return authFor(gitURL)
+}
+
+func headHash(repo *git.Repository) (string, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return "", utilities.NewError(utilities.PackageError, "cannot resolve HEAD", err)
+	}
+	return head.Hash().String(), nil
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package requests
+
+import (
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// scpLikePattern matches ssh addresses in the form of [user@]host:path
+var scpLikePattern = regexp.MustCompile(`^(?:([\w.-]+)@)?([\w.-]+):([^/].*)$`)
+
+// GitURL is a parsed git remote address
+type GitURL struct {
+	// Address is the remote address that is passed to git
+	Address string
+	// Scheme is one of https, http, git, ssh and file
+	Scheme string
+	User   string
+	Host   string
+	// Path is the repository path on the host, without the .git suffix
+	Path string
+}
+
+// IsGitURL returns true if the remote address points to a git repository
+func IsGitURL(remoteURL string) bool {
+	_, err := ParseGitURL(remoteURL)
+	return err == nil
+}
+
+// IsLocalPath returns true if the address is a path on the local file system,
+// i.e. it is absolute or starts with ./ or ../
+func IsLocalPath(address string) bool {
+	return address == "." || address == ".." || strings.HasPrefix(address, "/") ||
+		strings.HasPrefix(address, "./") || strings.HasPrefix(address, "../")
+}
+
+// ParseGitURL parses http(s)://, git://, ssh://, file:// and [user@]host:path
+// addresses of any git host, as well as local paths.
+func ParseGitURL(remoteURL string) (*GitURL, error) {
+	gitURL := &GitURL{Address: remoteURL}
+	switch {
+	case strings.Contains(remoteURL, "://"):
+		parsed, err := url.Parse(remoteURL)
+		if err != nil {
+			return nil, utilities.NewError(utilities.RequestError, "invalid git address "+remoteURL, err)
+		}
+		switch parsed.Scheme {
+		case "https", "http", "git", "ssh", "file":
+		default:
+			return nil, utilities.NewError(utilities.RequestError, "unsupported git protocol "+parsed.Scheme, nil)
+		}
+		gitURL.Scheme = parsed.Scheme
+		gitURL.Host = parsed.Hostname()
+		gitURL.Path = parsed.Path
+		if parsed.User != nil {
+			gitURL.User = parsed.User.Username()
+		}
+	case IsLocalPath(remoteURL):
+		absPath, err := filepath.Abs(remoteURL)
+		if err != nil {
+			return nil, utilities.NewError(utilities.RequestError, "invalid path "+remoteURL, err)
+		}
+		gitURL.Scheme = "file"
+		gitURL.Path = filepath.ToSlash(absPath)
+	case scpLikePattern.MatchString(remoteURL):
+		matches := scpLikePattern.FindStringSubmatch(remoteURL)
+		gitURL.Scheme = "ssh"
+		gitURL.User = matches[1]
+		gitURL.Host = matches[2]
+		gitURL.Path = matches[3]
+	default:
+		return nil, utilities.NewError(utilities.RequestError, "unsupported git address "+remoteURL, nil)
+	}
+	gitURL.Path = strings.TrimSuffix(strings.Trim(gitURL.Path, "/"), ".git")
+	if gitURL.Name() == "" {
+		return nil, utilities.NewError(utilities.RequestError, "cannot find the repository name in "+remoteURL, nil)
+	}
+	return gitURL, nil
+}
+
+// Name returns the name of the repository, i.e. the last part of the path
+func (gitURL *GitURL) Name() string {
+	segments := strings.Split(gitURL.Path, "/")
+	return segments[len(segments)-1]
+}
+
+// Vendor returns the namespace of the repository. Nested groups, e.g.
+// group/subgroup/name on GitLab, are joined by "-" so that the vendor can
+// still be used in [vendor]/[package]/[solver].
+func (gitURL *GitURL) Vendor() string {
+	segments := strings.Split(gitURL.Path, "/")
+	if len(segments) < 2 {
+		return "local"
+	}
+	if gitURL.Scheme == "file" {
+		return segments[len(segments)-2]
+	}
+	return strings.Join(segments[:len(segments)-1], "-")
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package requests
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGitURL(t *testing.T) {
+	absPath := func(path string) string {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return filepath.ToSlash(abs)
+	}
+	tests := []struct {
+		address string
+		want    GitURL
+		vendor  string
+		wantErr bool
+	}{
+		{address: "https://github.com/autoai-org/aid.git",
+			want:   GitURL{Scheme: "https", Host: "github.com", Path: "autoai-org/aid"},
+			vendor: "autoai-org"},
+		{address: "https://user@gitlab.com/group/subgroup/name",
+			want:   GitURL{Scheme: "https", User: "user", Host: "gitlab.com", Path: "group/subgroup/name"},
+			vendor: "group-subgroup"},
+		{address: "ssh://git@example.com:2222/vendor/name.git",
+			want:   GitURL{Scheme: "ssh", User: "git", Host: "example.com", Path: "vendor/name"},
+			vendor: "vendor"},
+		{address: "git@github.com:vendor/name.git",
+			want:   GitURL{Scheme: "ssh", User: "git", Host: "github.com", Path: "vendor/name"},
+			vendor: "vendor"},
+		{address: "file:///srv/git/vendor/name",
+			want:   GitURL{Scheme: "file", Path: "srv/git/vendor/name"},
+			vendor: "vendor"},
+		{address: "/srv/git/vendor/name.git",
+			want:   GitURL{Scheme: "file", Path: "srv/git/vendor/name"},
+			vendor: "vendor"},
+		{address: "./mypkg", want: GitURL{Scheme: "file", Path: absPath("./mypkg")[1:]}},
+		{address: "../mypkg", want: GitURL{Scheme: "file", Path: absPath("../mypkg")[1:]}},
+		{address: "ftp://example.com/vendor/name", wantErr: true},
+		{address: "vendor/name", wantErr: true},
+		{address: "https://github.com/", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseGitURL(test.address)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseGitURL(%q) error = %v, want error %v", test.address, err, test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+		test.want.Address = test.address
+		if *got != test.want {
+			t.Errorf("ParseGitURL(%q) = %+v, want %+v", test.address, *got, test.want)
+		}
+		if test.vendor != "" && got.Vendor() != test.vendor {
+			t.Errorf("ParseGitURL(%q).Vendor() = %q, want %q", test.address, got.Vendor(), test.vendor)
+		}
+	}
+}
+
+func TestSplitRef(t *testing.T) {
+	tests := []struct {
+		address string
+		remote  string
+		ref     string
+	}{
+		{address: "https://github.com/vendor/name", remote: "https://github.com/vendor/name"},
+		{address: "https://github.com/vendor/name@v1.0.0", remote: "https://github.com/vendor/name", ref: "v1.0.0"},
+		{address: "https://user@github.com/vendor/name", remote: "https://user@github.com/vendor/name"},
+		{address: "https://user@github.com/vendor/name@feature/x", remote: "https://user@github.com/vendor/name", ref: "feature/x"},
+		{address: "git@github.com:vendor/name.git", remote: "git@github.com:vendor/name.git"},
+		{address: "git@github.com:vendor/name.git@main", remote: "git@github.com:vendor/name.git", ref: "main"},
+		{address: "./mypkg@dev", remote: "./mypkg", ref: "dev"},
+		{address: "../mypkg", remote: "../mypkg"},
+		{address: "/srv/git/name@v2", remote: "/srv/git/name", ref: "v2"},
+	}
+	for _, test := range tests {
+		remote, ref := SplitRef(test.address)
+		if remote != test.remote || ref != test.ref {
+			t.Errorf("SplitRef(%q) = %q, %q, want %q, %q", test.address, remote, ref, test.remote, test.ref)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package requests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+)
+
+const (
+	// HealthStarting means the solver server is not ready yet
+	HealthStarting = "starting"
+	// HealthHealthy means the solver server answers requests
+	HealthHealthy = "healthy"
+	// HealthUnhealthy means the solver server failed its health checks
+	HealthUnhealthy = "unhealthy"
+)
+
+// probeTimeout limits a single health check request
+const probeTimeout = 3 * time.Second
+
+// SolverAddress returns the address the solver of the container is reachable at
+func SolverAddress(container *ent.Container) string {
+	host := container.BindAddress
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, container.Port)
+}
+
+// ProbeSolver checks that the solver server at address answers GET / with {"status": "OK"}
+func ProbeSolver(ctx context.Context, address string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected status " + strconv.Itoa(resp.StatusCode))
+	}
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil || status.Status != "OK" {
+		return errors.New("unexpected response, expected {\"status\": \"OK\"}")
+	}
+	return nil
+}
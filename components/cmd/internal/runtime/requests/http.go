@@ -8,6 +8,8 @@ package requests
 import (
 	"context"
 	"os"
+	"path/filepath"
+	"time"
 
 	entContainer "github.com/autoai-org/aid/ent/generated/container"
 	"github.com/autoai-org/aid/internal/database"
@@ -15,6 +17,9 @@ import (
 	"github.com/levigross/grequests"
 )
 
+// batchTimeout limits batch inference, which answers once all rows are done
+const batchTimeout = time.Hour
+
 var defaultHTTPClient *HTTPClient
 
 // HTTPClient is the basic structure for performing http requests
@@ -29,19 +34,96 @@ func NewHTTPClient() *HTTPClient {
 	return defaultHTTPClient
 }
 
-// Infer makes a http request to the given solver
-func (httpclient *HTTPClient) Infer(containerID string, params map[string]string) (*grequests.Response, error) {
-	containerEnt, err := database.NewDefaultDB().Container.Query().Where(entContainer.UID(containerID)).First(context.Background())
+// Infer makes a http request to the given solver, the file at filePath is
+// uploaded as the multipart field file if filePath is not empty
+func (httpclient *HTTPClient) Infer(containerID string, params map[string]string, filePath string) (*grequests.Response, error) {
+	address, err := readySolver(containerID)
+	if err != nil {
+		return nil, err
+	}
+	options := &grequests.RequestOptions{Data: params}
+	if filePath != "" {
+		upload, err := fileUpload(filePath)
+		if err != nil {
+			return nil, err
+		}
+		options.Files = []grequests.FileUpload{upload}
+	}
+	resp, err := grequests.Post("http://"+address+"/infer", options)
 	if err != nil {
-		utilities.Formatter.Error("Cannot fetch container: " + err.Error())
-		os.Exit(3)
+		return resp, utilities.NewError(utilities.NetworkError, "cannot handle requests from "+containerID, err)
 	}
-	if containerEnt.Running != true {
-		utilities.Formatter.Error("Container is not running, Aborted")
-		os.Exit(4)
+	return resp, nil
+}
+
+// BatchInfer uploads the csv file at inputPath to the given solver, which
+// performs the inference on every row, and downloads the rows with their
+// results to outputPath
+func (httpclient *HTTPClient) BatchInfer(containerID string, inputPath string, outputPath string) error {
+	address, err := readySolver(containerID)
+	if err != nil {
+		return err
 	}
-	resp, err := grequests.Post("http://127.0.0.1:"+containerEnt.Port+"/infer", &grequests.RequestOptions{
-		Data: params,
+	upload, err := fileUpload(inputPath)
+	if err != nil {
+		return err
+	}
+	resp, err := grequests.Post("http://"+address+"/batch", &grequests.RequestOptions{
+		Files:          []grequests.FileUpload{upload},
+		RequestTimeout: batchTimeout,
 	})
-	return resp, err
+	if err != nil {
+		return utilities.NewError(utilities.NetworkError, "cannot handle requests from "+containerID, err)
+	}
+	if !resp.Ok {
+		return utilities.NewError(utilities.RequestError, "batch inference failed with status "+resp.RawResponse.Status+": "+resp.String(), nil)
+	}
+	var result struct {
+		Filename string `json:"filename"`
+	}
+	if err := resp.JSON(&result); err != nil || result.Filename == "" {
+		return utilities.NewError(utilities.RequestError, "unexpected response from "+containerID+", expected the name of the result file", err)
+	}
+	// the results are served by the solver as static files
+	resp, err = grequests.Get("http://"+address+"/static/"+result.Filename, nil)
+	if err != nil {
+		return utilities.NewError(utilities.NetworkError, "cannot download "+result.Filename+" from "+containerID, err)
+	}
+	if !resp.Ok {
+		return utilities.NewError(utilities.RequestError, "cannot download "+result.Filename+" from "+containerID+", status "+resp.RawResponse.Status, nil)
+	}
+	if err := resp.DownloadToFile(outputPath); err != nil {
+		return utilities.NewError(utilities.UnknownError, "cannot write file "+outputPath, err)
+	}
+	return nil
+}
+
+// readySolver returns the address of the solver in the container, if it is
+// running and ready to answer requests
+func readySolver(containerID string) (string, error) {
+	containerEnt, err := database.NewDefaultDB().Container.Query().Where(entContainer.UID(containerID)).First(context.Background())
+	if err != nil {
+		return "", utilities.NewError(utilities.DatabaseError, "cannot fetch container "+containerID, err)
+	}
+	if !containerEnt.Running {
+		return "", utilities.NewError(utilities.RequestError, "container "+containerID+" is not running", nil)
+	}
+	address := SolverAddress(containerEnt)
+	// the health is only known if the daemon watches the docker health checks
+	if containerEnt.Health != HealthHealthy {
+		if err := ProbeSolver(context.Background(), address); err != nil {
+			return "", utilities.NewError(utilities.RequestError, "container "+containerID+" is not ready yet, wait for it with aid start --wait", err)
+		}
+	}
+	return address, nil
+}
+
+// fileUpload opens the file to be sent as the multipart field file, it is
+// closed once the request has been sent
+func fileUpload(filePath string) (grequests.FileUpload, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return grequests.FileUpload{}, utilities.NewError(utilities.RequestError, "cannot open file "+filePath, err)
+	}
+	return grequests.FileUpload{FileName: filepath.Base(filePath), FileContents: file, FieldName: "file"}, nil
 }
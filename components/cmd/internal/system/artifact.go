@@ -4,3 +4,304 @@
 // https://opensource.org/licenses/MIT
 
 package system
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// ArtifactSource is a storage backend that artifacts, e.g. pretrained models,
+// are downloaded from
+type ArtifactSource interface {
+	// Opener returns the function that reads the artifact at location
+	Opener(location *url.URL) (utilities.Opener, error)
+}
+
+var artifactSources = struct {
+	sync.Mutex
+	sources map[string]ArtifactSource
+}{sources: make(map[string]ArtifactSource)}
+
+// RegisterArtifactSource uses source for all artifact urls with the scheme,
+// it replaces the built-in source of the scheme if there is one
+func RegisterArtifactSource(scheme string, source ArtifactSource) {
+	artifactSources.Lock()
+	defer artifactSources.Unlock()
+	artifactSources.sources[strings.ToLower(scheme)] = source
+}
+
+// ArtifactOpener routes the artifact url to the source of its scheme.
+// http(s)://, s3://[bucket]/[key] and file:// urls are supported by
+// default, urls without a scheme are local paths. Local files are only read
+// from the LocalFolders of the config.
+func ArtifactOpener(location string) (utilities.Opener, error) {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return nil, utilities.NewError(utilities.RequestError, "invalid artifact url "+location, err)
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	artifactSources.Lock()
+	source, ok := artifactSources.sources[scheme]
+	artifactSources.Unlock()
+	if !ok {
+		artifactConfig := NewDefaultConfig().Artifacts
+		switch scheme {
+		case "http", "https":
+			source = &HTTPSource{Headers: artifactConfig.Headers, Tokens: artifactConfig.Tokens}
+		case "s3":
+			source = NewS3Source(artifactConfig.S3)
+		case "file", "":
+			source = &FileSource{Folders: artifactConfig.LocalFolders}
+		default:
+			return nil, utilities.NewError(utilities.RequestError, "unsupported artifact url "+location, nil)
+		}
+	}
+	return source.Opener(parsed)
+}
+
+// HTTPSource downloads artifacts from http(s) servers
+type HTTPSource struct {
+	// Headers maps hosts to the headers sent with every request to them
+	Headers map[string]map[string]string
+	// Tokens maps hosts to the bearer tokens sent with every request to them
+	Tokens map[string]string
+}
+
+// Opener returns an opener that adds the headers and the token of the host
+func (s *HTTPSource) Opener(location *url.URL) (utilities.Opener, error) {
+	headers := s.Headers[location.Host]
+	token := s.Tokens[location.Host]
+	return utilities.HTTPOpener(location.String(), func(req *http.Request) error {
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return nil
+	}), nil
+}
+
+// FileSource reads artifacts from the local file system or mounted shares
+type FileSource struct {
+	// Folders are the only folders files may be read from, as the urls
+	// come from packages that should not read e.g. the ssh keys of the user
+	Folders []string
+}
+
+// Opener returns an opener that reads the file at the path of the url
+func (s *FileSource) Opener(location *url.URL) (utilities.Opener, error) {
+	if location.Host != "" && location.Host != "localhost" {
+		return nil, utilities.NewError(utilities.RequestError, "cannot read files from the remote host "+location.Host, nil)
+	}
+	path := location.Path
+	if location.Scheme == "" {
+		path = location.String()
+	}
+	path, err := s.allowedPath(filepath.FromSlash(path))
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, partial utilities.Partial) (*utilities.Content, error) {
+		file, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				return nil, utilities.PermanentError(err)
+			}
+			return nil, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if info.IsDir() {
+			file.Close()
+			return nil, utilities.PermanentError(errors.New(path + " is a directory"))
+		}
+		// the partial copy is only resumed if the file was not modified since
+		validator := strconv.FormatInt(info.Size(), 10) + "-" + strconv.FormatInt(info.ModTime().UnixNano(), 10)
+		offset := partial.Size
+		if offset > info.Size() || partial.Validator != validator {
+			offset = 0
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &utilities.Content{ReadCloser: file, Start: offset, Total: info.Size(), Validator: validator}, nil
+	}, nil
+}
+
+// allowedPath resolves the symlinks of path and returns an error if it is not inside the folders
+func (s *FileSource) allowedPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", utilities.NewError(utilities.RequestError, "the local path "+path+" should be absolute", nil)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", utilities.NewError(utilities.RequestError, "cannot read "+path, err)
+	}
+	for _, folder := range s.Folders {
+		resolvedFolder, err := filepath.EvalSymlinks(folder)
+		if err != nil {
+			continue
+		}
+		if relPath, err := filepath.Rel(resolvedFolder, resolved); err == nil && relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", utilities.NewError(utilities.RequestError, "cannot read "+path+", local files are only read from Artifacts.LocalFolders in config.toml", nil)
+}
+
+// s3EmptyPayload is the sha256 of an empty request body
+const s3EmptyPayload = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Source downloads artifacts from Amazon S3 or S3 compatible stores, e.g.
+// MinIO. Objects are addressed as [endpoint]/[bucket]/[key] so that stores
+// without virtual host support work as well. Requests are anonymous if no
+// access key is set.
+type S3Source struct {
+	Endpoint     string
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// NewS3Source returns a source with the config, overridden by the environment
+func NewS3Source(s3Config S3Config) *S3Source {
+	source := &S3Source{
+		Endpoint:     utilities.FirstNonEmpty(os.Getenv("AID_S3_ENDPOINT"), s3Config.Endpoint),
+		Region:       utilities.FirstNonEmpty(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), s3Config.Region, "us-east-1"),
+		AccessKey:    utilities.FirstNonEmpty(os.Getenv("AWS_ACCESS_KEY_ID"), s3Config.AccessKey),
+		SecretKey:    utilities.FirstNonEmpty(os.Getenv("AWS_SECRET_ACCESS_KEY"), s3Config.SecretKey),
+		SessionToken: utilities.FirstNonEmpty(os.Getenv("AWS_SESSION_TOKEN"), s3Config.SessionToken),
+	}
+	if source.Endpoint == "" {
+		source.Endpoint = "https://s3." + source.Region + ".amazonaws.com"
+	}
+	return source
+}
+
+// Opener returns an opener that reads s3://[bucket]/[key] with signed requests
+func (s *S3Source) Opener(location *url.URL) (utilities.Opener, error) {
+	key := strings.TrimPrefix(location.Path, "/")
+	if location.Host == "" || key == "" {
+		return nil, utilities.NewError(utilities.RequestError, "invalid s3 url "+location.String()+", expected s3://[bucket]/[key]", nil)
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, utilities.NewError(utilities.RequestError, "invalid s3 endpoint "+s.Endpoint, err)
+	}
+	object := *endpoint
+	object.Path = endpoint.Path + "/" + location.Host + "/" + key
+	object.RawPath = s3EscapePath(object.Path)
+	return utilities.HTTPOpener(object.String(), s.sign), nil
+}
+
+// sign adds an AWS signature version 4 to the request
+func (s *S3Source) sign(req *http.Request) error {
+	if s.AccessKey == "" {
+		return nil
+	}
+	s.signAt(req, time.Now())
+	return nil
+}
+
+// signAt signs the request at the given time, the host and every header set
+// on the request are signed
+func (s *S3Source) signAt(req *http.Request, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3EmptyPayload)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	canonicalRequest, signedHeaders := s3CanonicalRequest(req)
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := s3StringToSign(amzDate, scope, canonicalRequest)
+	signature := hex.EncodeToString(hmacSha256(s3SigningKey(s.SecretKey, date, s.Region), stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// s3CanonicalRequest returns the canonical form of a request without body
+// and the list of its signed headers
+func s3CanonicalRequest(req *http.Request) (string, string) {
+	headers := map[string]string{"host": req.URL.Host}
+	for key, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for idx, value := range values {
+			trimmed[idx] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[strings.ToLower(key)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	return strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		// spaces are encoded as %20 in signatures
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3EmptyPayload,
+	}, "\n"), signedHeaders
+}
+
+func s3StringToSign(amzDate string, scope string, canonicalRequest string) string {
+	requestDigest := sha256.Sum256([]byte(canonicalRequest))
+	return "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestDigest[:])
+}
+
+func s3SigningKey(secretKey string, date string, region string) []byte {
+	signingKey := hmacSha256([]byte("AWS4"+secretKey), date)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		signingKey = hmacSha256(signingKey, part)
+	}
+	return signingKey
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath escapes everything but unreserved characters and slashes, as S3 expects in signed paths
+func s3EscapePath(path string) string {
+	var escaped strings.Builder
+	for _, b := range []byte(path) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || strings.IndexByte("-_.~/", b) >= 0 {
+			escaped.WriteByte(b)
+			continue
+		}
+		escaped.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{b})))
+	}
+	return escaped.String()
+}
@@ -18,6 +18,62 @@ import (
 // SystemConfig stores system level configuration, will be stored under $aid/config.toml
 type SystemConfig struct {
 	RemoteReport bool
+	// Registry is the address of the package registry, a http(s) url,
+	// a file:// url or a local path. It can be overridden by $AID_REGISTRY
+	Registry string
+	// Git stores the credentials used to clone private repositories
+	Git GitConfig
+	// DownloadConcurrency is the number of pretrained models downloaded in parallel
+	DownloadConcurrency int
+	// PortRange is the range, e.g. 18000-18999, host ports are allocated
+	// from if aid create is not given a port
+	PortRange string
+	// LoadBalancing is how the gateway spreads the requests of a solver over
+	// its replicas, round-robin (default) or least-connections
+	LoadBalancing string
+	// Artifacts stores the credentials used to download pretrained models
+	Artifacts ArtifactConfig
+	// AllowedOrigins are the web origins, e.g. http://localhost:8080 of the
+	// studio, whose pages may change aid through the daemon api
+	AllowedOrigins []string
+}
+
+// ArtifactConfig stores the credentials of the artifact sources
+type ArtifactConfig struct {
+	// Headers maps hosts to the http headers sent with every request to them
+	Headers map[string]map[string]string
+	// Tokens maps hosts to the bearer tokens sent with every request to them
+	Tokens map[string]string
+	S3     S3Config
+	// LocalFolders are the folders, e.g. a mounted share, that packages may
+	// read models from with file:// urls or absolute paths
+	LocalFolders []string
+}
+
+// S3Config stores the endpoint and credentials of an S3 compatible store,
+// they can be overridden by $AID_S3_ENDPOINT, $AWS_REGION, $AWS_ACCESS_KEY_ID,
+// $AWS_SECRET_ACCESS_KEY and $AWS_SESSION_TOKEN
+type S3Config struct {
+	// Endpoint is the address of the store, e.g. http://localhost:9000 for
+	// a local MinIO. Amazon S3 in Region is used if it is empty.
+	Endpoint     string
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// GitConfig stores git credentials, they can be overridden by $AID_GIT_USERNAME,
+// $AID_GIT_TOKEN, $AID_GIT_TOKEN_HOST, $AID_GIT_SSH_KEY and $AID_GIT_SSH_PASSPHRASE
+type GitConfig struct {
+	Username string
+	// Token is only sent to TokenHost, if it has no token in Tokens
+	Token     string
+	TokenHost string
+	// Tokens maps hosts, e.g. gitea.example.com, to their access tokens
+	Tokens        map[string]string
+	SSHKey        string
+	SSHPassphrase string
 }
 
 // DefaultConfig is the instance shared by all modules
@@ -28,7 +84,7 @@ func NewDefaultConfig() *SystemConfig {
 	if DefaultConfig != nil {
 		return DefaultConfig
 	}
-	DefaultConfig := ReadConfig()
+	DefaultConfig = ReadConfig()
 	return DefaultConfig
 }
 
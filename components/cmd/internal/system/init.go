@@ -15,17 +15,20 @@ func initFolders() {
 	utilities.CreateFolderIfNotExist(vendorDir)
 	targetDir := filepath.Join(vendorDir, "aid")
 	utilities.CreateFolderIfNotExist(targetDir)
-	requiredFolders := [5]string{"logs", "models", "plugins", "datasets", "temp"}
+	requiredFolders := [7]string{"logs", "models", "plugins", "datasets", "temp", "registry", "cache"}
 	for _, each := range requiredFolders {
 		utilities.CreateFolderIfNotExist(filepath.Join(targetDir, each))
 	}
-	initConfig := SystemConfig{RemoteReport: true}
-	SaveConfig(initConfig)
+	// keep the existing config
+	if !utilities.IsFileExists(filepath.Join(targetDir, "config.toml")) {
+		initConfig := SystemConfig{RemoteReport: true}
+		SaveConfig(initConfig)
+	}
 }
 
 // migrateDB is performed everytime before the system is started
 func migrateDB() {
-	if err := database.NewDefaultDB().Schema.Create(context.Background()); err != nil {
+	if err := database.Migrate(context.Background()); err != nil {
 		log.Fatalf("failed creating schema resources: %v", err)
 	}
 }
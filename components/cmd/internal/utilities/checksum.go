@@ -0,0 +1,56 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package utilities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// sha256Pattern matches hex encoded sha256 digests
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// NormalizeSha256 lowercases the digest and strips its optional sha256:
+// prefix, it returns an error if the digest is not 64 hex characters, e.g.
+// as it is used in file paths
+func NormalizeSha256(digest string) (string, error) {
+	normalized := strings.ToLower(strings.TrimPrefix(digest, "sha256:"))
+	if !sha256Pattern.MatchString(normalized) {
+		return "", errors.New("invalid sha256 " + digest + ", expected 64 hex characters")
+	}
+	return normalized, nil
+}
+
+// Sha256File returns the hex encoded sha256 digest of the file
+func Sha256File(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// VerifySha256 returns an error if the sha256 digest of the file is not expected
+func VerifySha256(filename string, expected string) error {
+	digest, err := Sha256File(filename)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(digest, strings.TrimPrefix(expected, "sha256:")) {
+		return errors.New("expected sha256 " + expected + ", got " + digest)
+	}
+	return nil
+}
@@ -10,4 +10,8 @@ const (
 	SentryID = "https://e6770124b98e44cfafa9d0e67e2d3650@sentry.io/1919735"
 	// MODELSFOLDER is under ~/.autoai/aid/models
 	MODELSFOLDER = "models"
+	// REGISTRYFOLDER is under ~/.autoai/aid/registry
+	REGISTRYFOLDER = "registry"
+	// CACHEFOLDER is under ~/.autoai/aid/cache
+	CACHEFOLDER = "cache"
 )
@@ -6,12 +6,17 @@
 package utilities
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dustin/go-humanize"
 )
@@ -33,44 +38,336 @@ func (wc *WriteCounter) Write(p []byte) (int, error) {
 // PrintProgress outputs the progress
 func (wc WriteCounter) PrintProgress() {
 	// Clear the line by using a character return to go back to the start and remove the remaining characters by filling it with spaces
-	fmt.Printf("\r%s", strings.Repeat(" ", 35))
+	fmt.Fprintf(LogWriter, "\r%s", strings.Repeat(" ", 35))
 
 	// Return again and print current status of download
 	// We use the humanize package to print the bytes in a meaningful way (e.g. 10 MB)
-	fmt.Printf("\rDownloading... %s complete", humanize.Bytes(wc.Total))
+	fmt.Fprintf(LogWriter, "\rDownloading... %s complete", humanize.Bytes(wc.Total))
+}
+
+// downloadRetries is how many times a failed download is retried by default
+const downloadRetries = 3
+
+// maxDownloadBackoff caps the wait between two download attempts
+const maxDownloadBackoff = 30 * time.Second
+
+// DownloadOptions describes the expected file, zero values are not checked
+type DownloadOptions struct {
+	Sha256 string
+	Size   int64
+	// Retries is the number of retries after the first attempt,
+	// downloadRetries is used if it is 0 and none are made if it is negative
+	Retries int
+	// Progress is called with the downloaded and the total bytes, total is
+	// -1 if it is unknown. Nothing is printed to stdout if it is set.
+	Progress func(downloaded int64, total int64)
+	// Open fetches the file, a plain HTTP GET of the url is used if it is nil
+	Open Opener
+}
+
+// Partial is the part of the file that a previous attempt downloaded
+type Partial struct {
+	Size int64
+	// Validator identifies the version of the remote file the part belongs
+	// to, e.g. its ETag. It is empty if the version is unknown.
+	Validator string
+}
+
+// Content is an opened remote file
+type Content struct {
+	io.ReadCloser
+	// Start is the offset the content starts at, it is 0 if the source
+	// cannot skip to the end of the partial download or the file changed
+	Start int64
+	// Total is the size of the file, -1 if it is unknown
+	Total int64
+	// Validator identifies the version of the file, it is empty if the
+	// source has none and the download cannot be resumed safely
+	Validator string
+}
+
+// Opener opens the remote file after the partial download
+type Opener func(ctx context.Context, partial Partial) (*Content, error)
+
+// validatorSuffix is appended to the .tmp file to keep the validator of a partial download
+const validatorSuffix = ".validator"
+
+// permanentError marks errors that retrying the download does not fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// PermanentError wraps err so that the download is not retried
+func PermanentError(err error) error {
+	return permanentError{err: err}
+}
+
+// progressWriter reports the number of bytes written to it
+type progressWriter struct {
+	downloaded int64
+	total      int64
+	progress   func(downloaded int64, total int64)
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	pw.downloaded += int64(len(p))
+	pw.progress(pw.downloaded, pw.total)
+	return len(p), nil
+}
+
+// httpStatusError is returned if the server responds with an unexpected status
+type httpStatusError struct {
+	status int
+}
+
+func (e httpStatusError) Error() string {
+	return "unexpected status " + strconv.Itoa(e.status) + " " + http.StatusText(e.status)
+}
+
+// retryable returns false for client errors that will not change by retrying
+func (e httpStatusError) retryable() bool {
+	return e.status >= 500 || e.status == http.StatusRequestTimeout || e.status == http.StatusTooManyRequests
 }
 
 // Download fetches the remote file and saves to local disk
 func Download(url string, dest string) error {
-	urlparams := strings.Split(url, "/")
-	filename := urlparams[len(urlparams)-1]
+	return DownloadWithOptions(context.Background(), url, dest, DownloadOptions{})
+}
+
+// DownloadWithOptions fetches the remote file into dest. The file is downloaded
+// into a .tmp file first, which is resumed from its current size if a previous
+// attempt failed, and only renamed once its size and sha256 are verified.
+func DownloadWithOptions(ctx context.Context, url string, dest string, options DownloadOptions) error {
+	filename := filepath.Base(strings.SplitN(url, "?", 2)[0])
 	targetFile := filepath.Join(dest, filename)
-	out, err := os.Create(targetFile + ".tmp")
+	if options.Sha256 != "" && IsFileExists(targetFile) && VerifySha256(targetFile, options.Sha256) == nil {
+		if options.Progress == nil {
+			Formatter.Info(filename + " has already been downloaded")
+		}
+		return nil
+	}
+	if err := os.MkdirAll(dest, os.ModePerm); err != nil {
+		return NewError(PackageError, "cannot create the folder "+dest, err)
+	}
+	retries := options.Retries
+	if retries == 0 {
+		retries = downloadRetries
+	} else if retries < 0 {
+		retries = 0
+	}
+	if options.Progress == nil {
+		Formatter.Info("Downloading pretrained model: " + filename)
+	}
+	var err error
+	backoff := time.Second
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			Formatter.Warn("Download of " + filename + " failed: " + err.Error() + ", retrying in " + backoff.String())
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > maxDownloadBackoff {
+				backoff = maxDownloadBackoff
+			}
+		}
+		err = downloadOnce(ctx, url, targetFile+".tmp", options)
+		if err == nil {
+			break
+		}
+		var statusErr httpStatusError
+		var permanentErr permanentError
+		if ctx.Err() != nil || errors.As(err, &permanentErr) || (errors.As(err, &statusErr) && !statusErr.retryable()) {
+			break
+		}
+	}
+	if err != nil {
+		return NewError(NetworkError, "cannot download "+url, err)
+	}
+	os.Remove(targetFile + ".tmp" + validatorSuffix)
+	if err := verifyDownload(targetFile+".tmp", options); err != nil {
+		// the partial file cannot be resumed anymore
+		os.Remove(targetFile + ".tmp")
+		return NewError(PackageError, "cannot verify "+filename, err)
+	}
+	if err := os.Rename(targetFile+".tmp", targetFile); err != nil {
+		return NewError(PackageError, "cannot move "+filename+" into place", err)
+	}
+	return nil
+}
+
+// HTTPOpener returns an Opener that downloads url with HTTP GET requests and
+// resumes with Range and If-Range requests, so that a file that changed is
+// downloaded again instead of appended to the old part. Partial downloads
+// without an ETag or Last-Modified are not resumed. prepare, if not nil, is
+// called with every request before it is sent, e.g. to add headers.
+func HTTPOpener(url string, prepare func(req *http.Request) error) Opener {
+	var open Opener
+	open = func(ctx context.Context, partial Partial) (*Content, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, PermanentError(err)
+		}
+		offset := int64(0)
+		if partial.Size > 0 && partial.Validator != "" {
+			offset = partial.Size
+			req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+			req.Header.Set("If-Range", partial.Validator)
+		}
+		if prepare != nil {
+			if err := prepare(req); err != nil {
+				return nil, PermanentError(err)
+			}
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && offset > 0:
+			start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+			if !ok || start != offset {
+				resp.Body.Close()
+				return nil, errors.New("unexpected Content-Range " + resp.Header.Get("Content-Range"))
+			}
+			return &Content{ReadCloser: resp.Body, Start: offset, Total: total, Validator: partial.Validator}, nil
+		case resp.StatusCode == http.StatusOK:
+			// the server does not support ranges or the file changed, start from scratch
+			return &Content{ReadCloser: resp.Body, Total: resp.ContentLength, Validator: responseValidator(resp)}, nil
+		case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+			resp.Body.Close()
+			// the partial file is only complete if it has the size of the file
+			if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && total == offset {
+				return &Content{ReadCloser: ioutil.NopCloser(strings.NewReader("")), Start: offset, Total: offset, Validator: partial.Validator}, nil
+			}
+			return open(ctx, Partial{})
+		}
+		resp.Body.Close()
+		return nil, httpStatusError{status: resp.StatusCode}
+	}
+	return open
+}
+
+// responseValidator returns the strong ETag of the response, or its
+// Last-Modified if there is none. Weak ETags cannot be used in If-Range.
+func responseValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// parseContentRange parses "bytes [start]-[end]/[total]" and "bytes */[total]",
+// the start is -1 and the total is -1 if they are missing or unknown
+func parseContentRange(contentRange string) (int64, int64, bool) {
+	if !strings.HasPrefix(contentRange, "bytes ") {
+		return 0, 0, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(contentRange, "bytes "), "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	start, total := int64(-1), int64(-1)
+	if parts[0] != "*" {
+		bounds := strings.SplitN(parts[0], "-", 2)
+		parsed, err := strconv.ParseInt(bounds[0], 10, 64)
+		if err != nil || len(bounds) != 2 {
+			return 0, 0, false
+		}
+		start = parsed
+	}
+	if parts[1] != "*" {
+		parsed, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		total = parsed
+	}
+	return start, total, true
+}
+
+// downloadOnce fetches the remote file into tmpFile, resuming from its current
+// size if the remote file did not change. The validator of the remote file is
+// kept next to tmpFile, so that a later process can resume as well.
+func downloadOnce(ctx context.Context, url string, tmpFile string, options DownloadOptions) error {
+	var partial Partial
+	if info, err := os.Stat(tmpFile); err == nil {
+		partial.Size = info.Size()
+		if validator, err := ioutil.ReadFile(tmpFile + validatorSuffix); err == nil {
+			partial.Validator = string(validator)
+		}
+	}
+	if options.Size > 0 && partial.Size >= options.Size {
+		// either complete, or larger than expected which is caught by verifyDownload
+		return nil
+	}
+	open := options.Open
+	if open == nil {
+		open = HTTPOpener(url, nil)
+	}
+	content, err := open(ctx, partial)
 	if err != nil {
 		return err
 	}
-	Formatter.Info("Downloading pretrained model: " + filename)
-	// Get the data
-	resp, err := http.Get(url)
+	body, start, total := content.ReadCloser, content.Start, content.Total
+	defer body.Close()
+	flags := os.O_CREATE | os.O_WRONLY
+	switch start {
+	case 0:
+		flags |= os.O_TRUNC
+		if err := ioutil.WriteFile(tmpFile+validatorSuffix, []byte(content.Validator), 0644); err != nil {
+			return err
+		}
+	case partial.Size:
+		flags |= os.O_APPEND
+	default:
+		return errors.New("the content starts at " + strconv.FormatInt(start, 10) + " instead of " + strconv.FormatInt(partial.Size, 10))
+	}
+	out, err := os.OpenFile(tmpFile, flags, 0644)
 	if err != nil {
-		out.Close()
 		return err
 	}
-	defer resp.Body.Close()
-	counter := &WriteCounter{}
-	if _, err = io.Copy(out, io.TeeReader(resp.Body, counter)); err != nil {
-		out.Close()
+	defer out.Close()
+	var written int64
+	if options.Progress != nil {
+		written, err = io.Copy(out, io.TeeReader(body, &progressWriter{downloaded: start, total: total, progress: options.Progress}))
+	} else {
+		counter := &WriteCounter{Total: uint64(start)}
+		written, err = io.Copy(out, io.TeeReader(body, counter))
+		// The progress use the same line so print a new line once it's finished downloading
+		fmt.Fprint(LogWriter, "\n")
+	}
+	if err != nil {
 		return err
 	}
+	if total >= 0 && start+written < total {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 
-	// The progress use the same line so print a new line once it's finished downloading
-	fmt.Print("\n")
-
-	// Close the file without defer so it can happen before Rename()
-	out.Close()
-
-	if err = os.Rename(targetFile+".tmp", targetFile); err != nil {
-		return err
+// verifyDownload checks the size and sha256 of the downloaded file
+func verifyDownload(filename string, options DownloadOptions) error {
+	if options.Size > 0 {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if info.Size() != options.Size {
+			return errors.New("expected " + strconv.FormatInt(options.Size, 10) + " bytes, got " + strconv.FormatInt(info.Size(), 10))
+		}
+	}
+	if options.Sha256 != "" {
+		return VerifySha256(filename, options.Sha256)
 	}
 	return nil
 }
@@ -0,0 +1,142 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package utilities
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		contentRange string
+		start        int64
+		total        int64
+		ok           bool
+	}{
+		{contentRange: "bytes 0-99/100", start: 0, total: 100, ok: true},
+		{contentRange: "bytes 50-99/100", start: 50, total: 100, ok: true},
+		{contentRange: "bytes 50-99/*", start: 50, total: -1, ok: true},
+		{contentRange: "bytes */100", start: -1, total: 100, ok: true},
+		{contentRange: ""},
+		{contentRange: "bytes 50/100"},
+		{contentRange: "bytes x-99/100"},
+		{contentRange: "bytes 0-99/x"},
+		{contentRange: "items 0-99/100"},
+	}
+	for _, test := range tests {
+		start, total, ok := parseContentRange(test.contentRange)
+		if ok != test.ok || ok && (start != test.start || total != test.total) {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v, want %d, %d, %v",
+				test.contentRange, start, total, ok, test.start, test.total, test.ok)
+		}
+	}
+}
+
+func TestDownloadWithOptions(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	digest := sha256.Sum256(content)
+	tests := []struct {
+		name string
+		// partial is the content of the .tmp file, there is none if it is nil
+		partial []byte
+		// validator is the content of the .tmp.validator file, there is none if it is empty
+		validator string
+		// wantRange is the Range header the server should receive
+		wantRange string
+	}{
+		{name: "full download"},
+		{name: "resume", partial: content[:4000], validator: `"v1"`, wantRange: "bytes=4000-"},
+		{name: "changed validator", partial: bytes.Repeat([]byte("x"), 4000), validator: `"v0"`, wantRange: "bytes=4000-"},
+		{name: "no validator", partial: bytes.Repeat([]byte("x"), 4000)},
+		{name: "complete partial", partial: content, validator: `"v1"`, wantRange: "bytes=10000-"},
+		{name: "partial larger than the file", partial: append(append([]byte{}, content...), 'x'), validator: `"v1"`, wantRange: "bytes=10001-"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var ranges []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				w.Header().Set("ETag", `"v1"`)
+				http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(content))
+			}))
+			defer server.Close()
+			dest := t.TempDir()
+			tmpFile := filepath.Join(dest, "model.bin.tmp")
+			if test.partial != nil {
+				if err := ioutil.WriteFile(tmpFile, test.partial, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.validator != "" {
+				if err := ioutil.WriteFile(tmpFile+validatorSuffix, []byte(test.validator), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := DownloadWithOptions(context.Background(), server.URL+"/model.bin", dest, DownloadOptions{
+				Sha256:   hex.EncodeToString(digest[:]),
+				Retries:  -1,
+				Progress: func(downloaded int64, total int64) {},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			downloaded, err := ioutil.ReadFile(filepath.Join(dest, "model.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(downloaded, content) {
+				t.Errorf("downloaded %d bytes that differ from the %d bytes of the file", len(downloaded), len(content))
+			}
+			if len(ranges) == 0 || ranges[0] != test.wantRange {
+				t.Errorf("requests had ranges %q, want %q first", ranges, test.wantRange)
+			}
+			for _, leftover := range []string{tmpFile, tmpFile + validatorSuffix} {
+				if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+					t.Errorf("%s is left behind", filepath.Base(leftover))
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadKeepsValidator(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Length", "10000")
+		w.WriteHeader(http.StatusOK)
+		// the connection is closed halfway through the file
+		w.Write(content[:4000])
+	}))
+	defer server.Close()
+	dest := t.TempDir()
+	err := DownloadWithOptions(context.Background(), server.URL+"/model.bin", dest, DownloadOptions{
+		Retries:  -1,
+		Progress: func(downloaded int64, total int64) {},
+	})
+	if err == nil {
+		t.Fatal("expected the interrupted download to fail")
+	}
+	partial, err := ioutil.ReadFile(filepath.Join(dest, "model.bin.tmp"))
+	if err != nil || !bytes.Equal(partial, content[:4000]) {
+		t.Errorf("partial download = %d bytes, %v, want the first 4000 bytes", len(partial), err)
+	}
+	validator, err := ioutil.ReadFile(filepath.Join(dest, "model.bin.tmp"+validatorSuffix))
+	if err != nil || strings.TrimSpace(string(validator)) != `"v1"` {
+		t.Errorf("validator = %q, %v, want %q", validator, err, `"v1"`)
+	}
+}
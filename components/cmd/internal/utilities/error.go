@@ -5,11 +5,70 @@
 
 package utilities
 
-import "os"
+import (
+	"errors"
+	"os"
+)
+
+// ErrorCategory is the kind of an error, its value is the exit code
+// documented in docs/specs/error-code.md
+type ErrorCategory int
+
+const (
+	// UnknownError is an internal unknown error
+	UnknownError ErrorCategory = 1
+	// NetworkError is a communication error with third-party servers
+	NetworkError ErrorCategory = 2
+	// DatabaseError is caused by the database, like conflicts in relations
+	DatabaseError ErrorCategory = 3
+	// RequestError means the request cannot be understood
+	RequestError ErrorCategory = 4
+	// DockerError is caused by the docker runtime
+	DockerError ErrorCategory = 5
+	// PackageError is caused by packages
+	PackageError ErrorCategory = 6
+)
+
+// Error is an error with a category, so that callers can decide how to
+// present it, e.g. as an exit code or a http status code.
+type Error struct {
+	Category ErrorCategory
+	Message  string
+	Err      error
+}
+
+// Error returns the message, followed by the underlying error if any
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewError returns an error of the given category, err may be nil
+func NewError(category ErrorCategory, message string, err error) error {
+	return &Error{Category: category, Message: message, Err: err}
+}
+
+// Category returns the category of err, UnknownError if it has none
+func Category(err error) ErrorCategory {
+	var categorized *Error
+	if errors.As(err, &categorized) {
+		return categorized.Category
+	}
+	return UnknownError
+}
 
 // ReportError is used to capture errors, and pass to central analytics
 // This function should be disabled, when report=False
 // Disable telemetry until final release.
+// It exits the program, so it should only be used when the system cannot
+// be initialized, other errors should be returned to the caller.
 func ReportError(err error, errorMessage string) {
 	if err != nil {
 		Formatter.Error(errorMessage + ": " + err.Error())
@@ -6,6 +6,8 @@
 package utilities
 
 import (
+	"context"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -84,13 +86,21 @@ func WriteContentToFile(filepath string, content string) error {
 	return nil
 }
 
-// GetRemoteFile returns a string that is included in a remote file
-func GetRemoteFile(url string) string {
+// GetRemoteFile returns the content of a remote file
+func GetRemoteFile(url string) (string, error) {
 	resp, err := http.Get(url)
-	ReportError(err, "Cannot fetch remote file..., Please check your internet connection!")
+	if err != nil {
+		return "", NewError(NetworkError, "cannot fetch "+url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", NewError(NetworkError, "cannot fetch "+url+": "+resp.Status, nil)
+	}
 	body, err := ioutil.ReadAll(resp.Body)
-	ReportError(err, "Cannot read from response..., Please check your internet connection!")
-	return string(body)
+	if err != nil {
+		return "", NewError(NetworkError, "cannot read "+url, err)
+	}
+	return string(body), nil
 }
 
 // ReadFileIfModified will return filecontent in byte mode if file has been modified
@@ -111,6 +121,63 @@ func ReadFileIfModified(filename string, lastMod time.Time) ([]byte, time.Time,
 	return msg, fi.ModTime(), nil
 }
 
+// FollowFile calls onData with the contents appended to filename since the
+// last call. The file is polled every interval until ctx is cancelled or done
+// returns true, the contents written before done returned true are still read.
+// A nil done never stops.
+func FollowFile(ctx context.Context, filename string, interval time.Duration, done func() bool, onData func([]byte)) error {
+	var offset int64
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		finished := done != nil && done()
+		var err error
+		if offset, err = readAppended(filename, offset, onData); err != nil {
+			return err
+		}
+		if finished {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// readAppended calls onData with the contents of filename after offset and
+// returns the new offset, a missing file has no contents yet
+func readAppended(filename string, offset int64, onData func([]byte)) (int64, error) {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return offset, nil
+	}
+	if err != nil {
+		return offset, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return offset, err
+	}
+	// the file has been truncated or rotated
+	if info.Size() < offset {
+		offset = 0
+	}
+	if info.Size() == offset {
+		return offset, nil
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+	data, err := ioutil.ReadAll(file)
+	if len(data) > 0 {
+		onData(data)
+	}
+	return offset + int64(len(data)), err
+}
+
 // IsFileExists is a shortcut to check if file exists
 func IsFileExists(filename string) bool {
 	_, err := os.Stat(filename)
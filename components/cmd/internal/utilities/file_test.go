@@ -0,0 +1,115 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package utilities
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadAppended(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log")
+	var got string
+	collect := func(data []byte) { got += string(data) }
+	read := func(offset int64) int64 {
+		t.Helper()
+		offset, err := readAppended(filename, offset, collect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return offset
+	}
+	write := func(content string, flags int) {
+		t.Helper()
+		file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|flags, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if _, err := file.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the file does not exist yet
+	if offset := read(0); offset != 0 || got != "" {
+		t.Fatalf("missing file: offset %d, read %q", offset, got)
+	}
+	write("abc", os.O_TRUNC)
+	offset := read(0)
+	if offset != 3 || got != "abc" {
+		t.Fatalf("new file: offset %d, read %q", offset, got)
+	}
+	// nothing was appended
+	if offset = read(offset); offset != 3 || got != "abc" {
+		t.Fatalf("unchanged file: offset %d, read %q", offset, got)
+	}
+	write("def", os.O_APPEND)
+	if offset = read(offset); offset != 6 || got != "abcdef" {
+		t.Fatalf("appended file: offset %d, read %q", offset, got)
+	}
+	// the file is truncated, e.g. rotated, and read from the start again
+	write("xy", os.O_TRUNC)
+	if offset = read(offset); offset != 2 || got != "abcdefxy" {
+		t.Fatalf("truncated file: offset %d, read %q", offset, got)
+	}
+}
+
+func TestFollowFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log")
+	var finished int32
+	go func() {
+		for _, line := range []string{"first\n", "second\n"} {
+			time.Sleep(20 * time.Millisecond)
+			file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return
+			}
+			file.WriteString(line)
+			file.Close()
+		}
+		// the last line is written right before the job finishes
+		atomic.StoreInt32(&finished, 1)
+	}()
+	var got string
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := FollowFile(ctx, filename, 5*time.Millisecond, func() bool {
+		return atomic.LoadInt32(&finished) == 1
+	}, func(data []byte) {
+		got += string(data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("FollowFile did not stop once done returned true")
+	}
+	if got != "first\nsecond\n" {
+		t.Errorf("followed %q", got)
+	}
+}
+
+func TestFollowFileCancel(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log")
+	if err := ioutil.WriteFile(filename, []byte("line\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var got string
+	err := FollowFile(ctx, filename, 5*time.Millisecond, nil, func(data []byte) {
+		got += string(data)
+		cancel()
+	})
+	if err != nil || got != "line\n" {
+		t.Errorf("FollowFile = %v, followed %q", err, got)
+	}
+}
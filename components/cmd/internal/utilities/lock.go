@@ -0,0 +1,50 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package utilities
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// FileLock is an advisory lock on a file, it is respected by all aid
+// processes, e.g. the cli and the workers of the daemon
+type FileLock struct {
+	file *os.File
+}
+
+// LockFile blocks until the lock of path is held, shared locks can be held
+// by several holders at once. The file is created if it does not exist.
+func LockFile(path string, shared bool) (*FileLock, error) {
+	return lockFile(path, shared, true)
+}
+
+// TryLockFile takes the exclusive lock of path if it is free, it returns
+// nil without an error if the lock is held by someone else
+func TryLockFile(path string) (*FileLock, error) {
+	return lockFile(path, false, false)
+}
+
+func lockFile(path string, shared bool, block bool) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := flock(file, shared, block)
+	if err != nil || !locked {
+		file.Close()
+		return nil, err
+	}
+	return &FileLock{file: file}, nil
+}
+
+// Unlock releases the lock, the file is kept as others may be waiting on it
+func (lock *FileLock) Unlock() {
+	lock.file.Close()
+}
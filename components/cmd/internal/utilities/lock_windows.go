@@ -0,0 +1,35 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+//go:build windows
+// +build windows
+
+package utilities
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// flock locks the first byte of the file with LockFileEx, the lock is
+// released when the file is closed
+func flock(file *os.File, shared bool, block bool) (bool, error) {
+	var flags uint32
+	if !shared {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	if !block {
+		flags |= windows.LOCKFILE_FAIL_IMMEDIATELY
+	}
+	err := windows.LockFileEx(windows.Handle(file.Fd()), flags, 0, 1, 0, &windows.Overlapped{})
+	switch err {
+	case nil:
+		return true, nil
+	case windows.ERROR_LOCK_VIOLATION:
+		return false, nil
+	}
+	return false, err
+}
@@ -25,15 +25,23 @@ func NewDefaultLogger() *logrus.Logger {
 		return DefaultLogger
 	}
 	logPath := filepath.Join(GetBasePath(), "logs", "system")
-	DefaultLogger = NewLogger(logPath)
+	logger, err := NewLogger(logPath)
+	if err != nil {
+		// the system log is not required to run, its entries go to stderr instead
+		logger = logrus.New()
+		logger.SetOutput(os.Stderr)
+		logger.Warn(err.Error())
+	}
+	DefaultLogger = logger
 	return DefaultLogger
 }
 
-// NewLogger returns a new Logger
-func NewLogger(logPath string) *logrus.Logger {
-	var logger *logrus.Logger
-	CreateFolderIfNotExist(filepath.Dir(logPath))
-	logger = logrus.New()
+// NewLogger returns a new Logger that writes to logPath
+func NewLogger(logPath string) (*logrus.Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(logPath), os.ModePerm); err != nil {
+		return nil, NewError(UnknownError, "cannot create the log folder "+filepath.Dir(logPath), err)
+	}
+	logger := logrus.New()
 	if Verbose {
 		rotateFileHook, err := NewRotateFileHook(RotateFileConfig{
 			Filename:   logPath,
@@ -45,16 +53,20 @@ func NewLogger(logPath string) *logrus.Logger {
 				TimestampFormat: time.RFC822,
 			},
 		})
-		logger.SetOutput(os.Stdout)
+		if err != nil {
+			return nil, NewError(UnknownError, "cannot initialize the logger of "+logPath, err)
+		}
+		// LogWriter is stderr for structured output, stdout only holds the result
+		logger.SetOutput(LogWriter)
 		logger.AddHook(rotateFileHook)
-		ReportError(err, "Cannot initialize logger")
 	} else {
 		file, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-		ReportError(err, "Cannot open file")
+		if err != nil {
+			return nil, NewError(UnknownError, "cannot open the log file "+logPath, err)
+		}
 		logger.SetOutput(file)
 	}
-
-	return logger
+	return logger, nil
 }
 
 var logger = NewDefaultLogger()
@@ -7,6 +7,8 @@ package utilities
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/gookit/color"
 )
@@ -18,6 +20,10 @@ type ColorPrinter struct {
 // Formatter is the extern object that we should use for color printing
 var Formatter *ColorPrinter
 
+// LogWriter is where Formatter prints, it is set to os.Stderr when the
+// output of the command is meant to be parsed
+var LogWriter io.Writer = os.Stdout
+
 // Info is the shortcut for basePrint(info,...)
 func (cp *ColorPrinter) Info(msg string) {
 	cp.basePrint("INFO", msg)
@@ -45,5 +51,5 @@ func (cp *ColorPrinter) basePrint(level string, msg string) {
 	default:
 		lvlColor = color.FgWhite
 	}
-	fmt.Printf("[%s] %s\n", lvlColor.Render(level), msg)
+	fmt.Fprintf(LogWriter, "[%s] %s\n", lvlColor.Render(level), msg)
 }
@@ -1,8 +1,11 @@
 package workflow
 
-import "github.com/autoai-org/aid/internal/runtime/docker"
+import (
+	ent "github.com/autoai-org/aid/ent/generated"
+	"github.com/autoai-org/aid/internal/runtime/docker"
+)
 
 // BuildDockerImage builds the docker image
-func BuildDockerImage(vendorName string, packageName string, solverName string) {
-	docker.BuildImage(vendorName, packageName, solverName)
+func BuildDockerImage(vendorName string, packageName string, solverName string) (*ent.Image, error) {
+	return docker.BuildImage(vendorName, packageName, solverName)
 }
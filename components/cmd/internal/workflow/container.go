@@ -5,15 +5,143 @@
 
 package workflow
 
-import "github.com/autoai-org/aid/internal/runtime/docker"
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	entImage "github.com/autoai-org/aid/ent/generated/image"
+	"github.com/autoai-org/aid/internal/configuration"
+	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/runtime/docker"
+	"github.com/autoai-org/aid/internal/system"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// portMutex prevents concurrent creations from allocating the same port,
+// portLock does the same across processes, e.g. aid scale and the daemon
+var portMutex sync.Mutex
+
+// portLock is the file locked while a host port is allocated and saved
+const portLock = "ports.lock"
 
 // CreateContainer creates a stopped container
-// The container can be then started by ```aid start```
-func CreateContainer(imageUID string, hostPort string) {
-	docker.Create(imageUID, hostPort)
+// The container can be then started by ```aid start```.
+// The [runtime] settings in aid.toml of the package are used, overrides
+// take precedence over them. A unique name is generated if name is empty
+// and a free host port is allocated if hostPort is empty.
+func CreateContainer(imageUID string, hostPort string, name string, overrides configuration.RuntimeManifest) (*ent.Container, error) {
+	if err := overrides.Validate(); err != nil {
+		return nil, utilities.NewError(utilities.RequestError, "invalid container settings", err)
+	}
+	repo, err := database.NewDefaultDB().Image.Query().Where(entImage.UID(imageUID)).QuerySolver().QueryRepository().First(context.Background())
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch the package of image "+imageUID, err)
+	}
+	manifest, err := configuration.ReadManifest(repo.Localpath)
+	if err != nil {
+		return nil, err
+	}
+	options, err := containerOptions(repo.Localpath, manifest.Runtime.Merge(overrides))
+	if err != nil {
+		return nil, err
+	}
+	options.Name = name
+	// the port is only assigned once the container is saved
+	portMutex.Lock()
+	defer portMutex.Unlock()
+	lock, err := utilities.LockFile(filepath.Join(utilities.GetBasePath(), portLock), false)
+	if err != nil {
+		return nil, utilities.NewError(utilities.UnknownError, "cannot lock the host ports", err)
+	}
+	defer lock.Unlock()
+	if hostPort == "" {
+		if hostPort, err = docker.AllocateHostPort(options.BindAddress, system.NewDefaultConfig().PortRange); err != nil {
+			return nil, err
+		}
+		utilities.Formatter.Info("Using host port " + hostPort)
+	} else if err := docker.CheckHostPort(options.BindAddress, hostPort); err != nil {
+		return nil, err
+	}
+	return docker.Create(imageUID, hostPort, options)
+}
+
+// containerOptions converts the runtime settings, relative host paths of
+// volumes are resolved against the package folder
+func containerOptions(packageFolder string, runtime configuration.RuntimeManifest) (docker.ContainerOptions, error) {
+	options := docker.ContainerOptions{
+		BindAddress: runtime.BindAddress,
+		CPUs:        runtime.CPUs,
+		Restart:     runtime.Restart,
+	}
+	memory, err := configuration.ParseMemory(runtime.Memory)
+	if err != nil {
+		return options, utilities.NewError(utilities.RequestError, "invalid memory limit "+runtime.Memory, err)
+	}
+	options.Memory = memory
+	for key, value := range runtime.Env {
+		options.Env = append(options.Env, key+"="+value)
+	}
+	sort.Strings(options.Env)
+	for _, volume := range runtime.Volumes {
+		hostPath, containerPath, readOnly, err := configuration.ParseVolume(volume)
+		if err != nil {
+			return options, utilities.NewError(utilities.RequestError, "invalid volume", err)
+		}
+		if !filepath.IsAbs(hostPath) {
+			// a cloned package may ship a symlink pointing out of its folder
+			if hostPath, err = resolveInside(packageFolder, hostPath); err != nil {
+				return options, err
+			}
+		}
+		bind := hostPath + ":" + containerPath
+		if readOnly {
+			bind += ":ro"
+		}
+		options.Volumes = append(options.Volumes, bind)
+	}
+	return options, nil
+}
+
+// resolveInside joins the relative path to the folder and resolves its
+// symlinks, it fails if the result is outside of the folder. Paths that do
+// not exist yet are created by docker, only their existing parents are resolved.
+func resolveInside(folder string, relPath string) (string, error) {
+	resolvedFolder, err := filepath.EvalSymlinks(folder)
+	if err != nil {
+		return "", utilities.NewError(utilities.PackageError, "cannot resolve the package folder "+folder, err)
+	}
+	resolved, err := resolveExisting(filepath.Join(resolvedFolder, relPath))
+	if err != nil {
+		return "", utilities.NewError(utilities.PackageError, "cannot resolve the host path "+relPath, err)
+	}
+	if inside, err := filepath.Rel(resolvedFolder, resolved); err != nil || !configuration.InsidePackage(inside) {
+		return "", utilities.NewError(utilities.RequestError, "the host path "+relPath+" should be inside the package folder, it resolves to "+resolved, err)
+	}
+	return resolved, nil
+}
+
+// resolveExisting resolves the symlinks of the longest existing prefix of path
+func resolveExisting(path string) (string, error) {
+	missing := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+		parent := filepath.Dir(path)
+		if !os.IsNotExist(err) || parent == path {
+			return "", err
+		}
+		missing = filepath.Join(filepath.Base(path), missing)
+		path = parent
+	}
 }
 
 // StartContainer starts a stopped container
-func StartContainer(containerUID string) {
-	docker.Start(containerUID)
+func StartContainer(containerUID string) error {
+	return docker.Start(containerUID)
 }
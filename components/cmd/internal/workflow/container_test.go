@@ -0,0 +1,70 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package workflow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveInside(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(root, "outside")
+	realFolder := filepath.Join(root, "package")
+	for _, folder := range []string{outside, filepath.Join(realFolder, "data")} {
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the package folder itself may be a symlink, e.g. to another disk
+	packageFolder := filepath.Join(root, "linked-package")
+	links := map[string]string{
+		packageFolder:                           realFolder,
+		filepath.Join(realFolder, "escape"):     outside,
+		filepath.Join(realFolder, "relative"):   "../outside",
+		filepath.Join(realFolder, "parent"):     "..",
+		filepath.Join(realFolder, "models"):     "data",
+		filepath.Join(realFolder, "data", "up"): "../../outside",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skip("symlinks are not supported: " + err.Error())
+		}
+	}
+	tests := []struct {
+		relPath string
+		want    string
+		wantErr bool
+	}{
+		{relPath: "data", want: filepath.Join(realFolder, "data")},
+		{relPath: "models", want: filepath.Join(realFolder, "data")},
+		{relPath: "models/weights", want: filepath.Join(realFolder, "data", "weights")},
+		{relPath: "missing/folder", want: filepath.Join(realFolder, "missing", "folder")},
+		{relPath: ".", want: realFolder},
+		{relPath: "..", wantErr: true},
+		{relPath: "../outside", wantErr: true},
+		{relPath: "escape", wantErr: true},
+		{relPath: "escape/missing", wantErr: true},
+		{relPath: "relative", wantErr: true},
+		{relPath: "parent/outside", wantErr: true},
+		{relPath: "data/up", wantErr: true},
+		{relPath: "models/up/file", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := resolveInside(packageFolder, test.relPath)
+		if (err != nil) != test.wantErr {
+			t.Errorf("resolveInside(%q) = %q, %v, want error %v", test.relPath, got, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("resolveInside(%q) = %q, want %q", test.relPath, got, test.want)
+		}
+	}
+}
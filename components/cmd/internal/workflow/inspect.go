@@ -0,0 +1,207 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package workflow
+
+import (
+	"context"
+	"strings"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	entContainer "github.com/autoai-org/aid/ent/generated/container"
+	entImage "github.com/autoai-org/aid/ent/generated/image"
+	entJob "github.com/autoai-org/aid/ent/generated/job"
+	entRepository "github.com/autoai-org/aid/ent/generated/repository"
+	entSolver "github.com/autoai-org/aid/ent/generated/solver"
+	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/runtime/cargo"
+	"github.com/autoai-org/aid/internal/runtime/docker"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// The types of entities found by Inspect
+const (
+	InspectPackage   = "package"
+	InspectSolver    = "solver"
+	InspectImage     = "image"
+	InspectContainer = "container"
+)
+
+// recentBuilds is the number of build jobs in the details of a package
+const recentBuilds = 5
+
+// Details is a package with the solvers, images and containers below it.
+// When a solver, an image or a container is inspected, only the branch
+// leading to it is kept.
+type Details struct {
+	// Type is the type of the inspected entity
+	Type    string           `json:"type"`
+	Package *ent.Repository  `json:"package"`
+	Solvers []*SolverDetails `json:"solvers"`
+	// Artifacts are the cached pretrained models linked by the package
+	Artifacts []cargo.CachedArtifact `json:"artifacts"`
+	// Builds are the recent build jobs of the solvers, the latest first
+	Builds []*ent.Job `json:"builds"`
+}
+
+// SolverDetails is a solver with its image and the containers of the image
+type SolverDetails struct {
+	Solver *ent.Solver `json:"solver"`
+	Image  *ent.Image  `json:"image,omitempty"`
+	// BuildID refers to the build log of the image, see aid logs
+	BuildID    string              `json:"build_id,omitempty"`
+	Containers []*ContainerDetails `json:"containers"`
+}
+
+// ContainerDetails is a container with its state on the docker host,
+// StateError is set instead if docker cannot be reached
+type ContainerDetails struct {
+	Container  *ent.Container         `json:"container"`
+	State      *docker.ContainerState `json:"state,omitempty"`
+	StateError string                 `json:"state_error,omitempty"`
+}
+
+// branch narrows the details of a package to the inspected entity, zero
+// ids match everything
+type branch struct {
+	solverID    int
+	imageID     int
+	containerID int
+}
+
+// Inspect returns the details of a package, a solver, an image or a
+// container. The id is the unique id of a package, an image or a container,
+// [vendor]/[package] or [vendor]/[package]/[solver].
+func Inspect(id string) (*Details, error) {
+	ctx := context.Background()
+	client := database.NewDefaultDB()
+	segments := strings.Split(id, "/")
+	switch len(segments) {
+	case 2:
+		repo, err := client.Repository.Query().Where(entRepository.Vendor(segments[0]), entRepository.Name(segments[1])).First(ctx)
+		if err != nil {
+			return nil, utilities.NewError(utilities.DatabaseError, "cannot find package "+id, err)
+		}
+		return packageDetails(ctx, InspectPackage, repo, branch{})
+	case 3:
+		solver, err := client.Solver.Query().
+			Where(entSolver.Name(segments[2]), entSolver.HasRepositoryWith(entRepository.Vendor(segments[0]), entRepository.Name(segments[1]))).
+			WithRepository().
+			First(ctx)
+		if err != nil {
+			return nil, utilities.NewError(utilities.DatabaseError, "cannot find solver "+id, err)
+		}
+		return packageDetails(ctx, InspectSolver, solver.Edges.Repository, branch{solverID: solver.ID})
+	}
+	if repo, err := client.Repository.Query().Where(entRepository.UID(id)).First(ctx); err == nil {
+		return packageDetails(ctx, InspectPackage, repo, branch{})
+	}
+	if image, err := client.Image.Query().Where(entImage.UID(id)).First(ctx); err == nil {
+		repo, err := image.QuerySolver().QueryRepository().First(ctx)
+		if err != nil {
+			return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch the package of image "+id, err)
+		}
+		return packageDetails(ctx, InspectImage, repo, branch{imageID: image.ID})
+	}
+	if container, err := client.Container.Query().Where(entContainer.UID(id)).WithImage().First(ctx); err == nil {
+		if container.Edges.Image == nil {
+			return nil, utilities.NewError(utilities.DatabaseError, "container "+id+" has no image", nil)
+		}
+		repo, err := container.Edges.Image.QuerySolver().QueryRepository().First(ctx)
+		if err != nil {
+			return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch the package of container "+id, err)
+		}
+		return packageDetails(ctx, InspectContainer, repo, branch{imageID: container.Edges.Image.ID, containerID: container.ID})
+	}
+	return nil, utilities.NewError(utilities.RequestError, "cannot find package, solver, image or container "+id, nil)
+}
+
+// packageDetails collects the details of the package on the branch
+func packageDetails(ctx context.Context, entityType string, repo *ent.Repository, on branch) (*Details, error) {
+	details := &Details{Type: entityType, Package: repo, Solvers: []*SolverDetails{}}
+	solvers, err := repo.QuerySolvers().All(ctx)
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch the solvers of "+repo.UID, err)
+	}
+	for _, solver := range solvers {
+		// solvers have no image before they are built
+		image, err := solver.QueryImage().Only(ctx)
+		if err != nil && !ent.IsNotFound(err) {
+			return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch the image of solver "+solver.Name, err)
+		}
+		if on.solverID != 0 && solver.ID != on.solverID || on.imageID != 0 && (image == nil || image.ID != on.imageID) {
+			continue
+		}
+		solverDetails := &SolverDetails{Solver: solver, Image: image, Containers: []*ContainerDetails{}}
+		if image != nil {
+			// the build id is missing if docker cannot be reached, the rest is still useful
+			solverDetails.BuildID, _ = docker.ImageBuildID(ctx, image.UID)
+			containers, err := imageContainers(image)
+			if err != nil {
+				return nil, err
+			}
+			for _, container := range containers {
+				if on.containerID == 0 || container.ID == on.containerID {
+					solverDetails.Containers = append(solverDetails.Containers, containerDetails(ctx, container))
+				}
+			}
+		}
+		details.Solvers = append(details.Solvers, solverDetails)
+	}
+	if details.Artifacts, err = packageArtifacts(repo); err != nil {
+		return nil, err
+	}
+	// the kind is jobs.KindBuild, jobs cannot be imported as it runs the workflows
+	builds := database.NewDefaultDB().Job.Query().Where(entJob.Kind("build"))
+	if entityType == InspectPackage {
+		builds = builds.Where(entJob.TargetHasPrefix(repo.Vendor + "/" + repo.Name + "/"))
+	} else {
+		var targets []string
+		for _, solver := range details.Solvers {
+			targets = append(targets, repo.Vendor+"/"+repo.Name+"/"+solver.Solver.Name)
+		}
+		builds = builds.Where(entJob.TargetIn(targets...))
+	}
+	details.Builds, err = builds.Order(ent.Desc(entJob.FieldCreatedAt)).Limit(recentBuilds).All(ctx)
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch the builds of "+repo.UID, err)
+	}
+	return details, nil
+}
+
+func containerDetails(ctx context.Context, container *ent.Container) *ContainerDetails {
+	details := &ContainerDetails{Container: container}
+	state, err := docker.InspectContainer(ctx, container.UID)
+	if err != nil {
+		details.StateError = err.Error()
+		return details
+	}
+	details.State = state
+	if state.Running {
+		// refreshes the saved health, it is kept if the check fails
+		if health, err := docker.CheckHealth(ctx, container); err == nil {
+			container.Health = health
+		}
+	}
+	return details
+}
+
+// packageArtifacts returns the cached artifacts linked by the package
+func packageArtifacts(repo *ent.Repository) ([]cargo.CachedArtifact, error) {
+	artifacts, err := cargo.ListArtifacts()
+	if err != nil {
+		return nil, err
+	}
+	used := []cargo.CachedArtifact{}
+	for _, artifact := range artifacts {
+		for _, user := range artifact.UsedBy {
+			if user == repo.Vendor+"/"+repo.Name {
+				used = append(used, artifact)
+				break
+			}
+		}
+	}
+	return used, nil
+}
@@ -7,64 +7,394 @@ package workflow
 
 import (
 	"context"
+	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
 
 	ent "github.com/autoai-org/aid/ent/generated"
+	entRepository "github.com/autoai-org/aid/ent/generated/repository"
 	"github.com/autoai-org/aid/internal/configuration"
 	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/runtime/cargo"
+	"github.com/autoai-org/aid/internal/runtime/registry"
 	"github.com/autoai-org/aid/internal/runtime/requests"
+	"github.com/autoai-org/aid/internal/system"
 	"github.com/autoai-org/aid/internal/utilities"
 )
 
 // PullPackageSource tried to download the source code of the file from remote
-// address, it now supports github and other git-based server.
-func PullPackageSource(remoteURL string) {
+// address, it now supports any git host over http(s), ssh and file://, and packages
+// published to a registry as [vendor]/[name]@[version]. Git addresses can be
+// pinned to a branch, tag or commit with [remote address]@[ref].
+func PullPackageSource(remoteURL string) (*ent.Repository, error) {
+	return PullPackageSourceContext(context.Background(), remoteURL)
+}
+
+// PullPackageSourceContext is the same as PullPackageSource, but the
+// download is aborted once ctx is cancelled. The package is staged in the
+// temp folder and only moved into place, together with its database rows,
+// once the manifest is valid and all pretrained models are downloaded.
+// Missing dependencies declared in aid.toml are installed first, and removed
+// again if the package cannot be installed.
+func PullPackageSourceContext(ctx context.Context, remoteURL string) (*ent.Repository, error) {
+	return pullPackage(ctx, remoteURL, nil, &installLog{})
+}
+
+// installLog records the packages installed by one installation, dependencies
+// are recorded before the packages that depend on them
+type installLog struct {
+	packages []*ent.Repository
+}
+
+// rollback removes the packages installed after mark, dependents first
+func (installs *installLog) rollback(mark int) {
+	for idx := len(installs.packages) - 1; idx >= mark; idx-- {
+		repo := installs.packages[idx]
+		utilities.Formatter.Info("Removing dependency " + repo.Vendor + "/" + repo.Name)
+		if err := cargo.RemovePackage(repo.UID); err != nil {
+			utilities.Formatter.Warn("Cannot remove dependency " + repo.Vendor + "/" + repo.Name + ": " + err.Error())
+		}
+	}
+	installs.packages = installs.packages[:mark]
+}
+
+// pullPackage installs the package from remoteURL, chain lists the packages
+// whose dependencies are being installed and is used to detect cycles. The
+// dependencies installed for the package are rolled back if it fails.
+func pullPackage(ctx context.Context, remoteURL string, chain []string, installs *installLog) (installed *ent.Repository, err error) {
+	mark := len(installs.packages)
+	defer func() {
+		if err != nil {
+			installs.rollback(mark)
+		}
+	}()
 	targetPath := filepath.Join(utilities.GetBasePath(), "models")
 	var remoteType string
-	var installedRepository *ent.Repository
+	var absTargetSubFolder string
 	imageID := utilities.GenerateUUIDv4()
-	if strings.HasPrefix(remoteURL, "https://github.com") {
-		remoteType = "Git"
-	} else if strings.HasPrefix(remoteURL, "git://") {
+	stagingFolder := filepath.Join(utilities.GetFolder("temp"), "install-"+imageID)
+	defer os.RemoveAll(stagingFolder)
+	// local paths are checked first, ../name would look like a package reference
+	if requests.IsLocalPath(remoteURL) {
 		remoteType = "Git"
-	} else {
+	} else if _, ok := registry.ParsePackageRef(remoteURL); ok {
 		remoteType = "Registry"
+	} else if requests.IsGitURL(remoteURL) {
+		remoteType = "Git"
 	}
+	var vendorName, repoName, version, ref, commit string
 	switch remoteType {
 	case "Git":
-		git := requests.NewGitClient()
-		localFolderName := strings.Split(remoteURL, "/")
-		vendorName := localFolderName[len(localFolderName)-2]
-		repoName := localFolderName[len(localFolderName)-1]
-		targetSubFolder := filepath.Join(targetPath, vendorName, repoName)
-		absTargetSubFolder, _ := filepath.Abs(targetSubFolder)
-		err := git.Clone(remoteURL, absTargetSubFolder)
-		if err == nil {
-			installedRepository, err = database.NewDefaultDB().Repository.Create().SetName(repoName).SetLocalpath(absTargetSubFolder).SetVendor(vendorName).SetUID(imageID).SetRemoteURL(remoteURL).SetStatus("Source Code Installed").Save(context.Background())
-			utilities.ReportError(err, "cannot save new package to database")
+		remoteURL, ref = requests.SplitRef(remoteURL)
+		gitURL, err := requests.ParseGitURL(remoteURL)
+		if err != nil {
+			return nil, err
+		}
+		if requests.IsLocalPath(remoteURL) {
+			// relative paths would not resolve from another working directory
+			if remoteURL, err = filepath.Abs(remoteURL); err != nil {
+				return nil, utilities.NewError(utilities.RequestError, "invalid path "+remoteURL, err)
+			}
+		}
+		vendorName, repoName = gitURL.Vendor(), gitURL.Name()
+		absTargetSubFolder, err = checkInstallTarget(ctx, filepath.Join(targetPath, vendorName, repoName))
+		if err != nil {
+			return nil, err
+		}
+		commit, err = requests.NewGitClient().CloneRef(ctx, remoteURL, stagingFolder, ref)
+		if err != nil {
+			return nil, err
 		}
 	case "Registry":
-		utilities.Formatter.Error("Registry will be supported in the near future.")
+		packageRef, _ := registry.ParsePackageRef(remoteURL)
+		packageRegistry := registry.Default()
+		release, err := packageRegistry.Resolve(packageRef)
+		if err != nil {
+			return nil, err
+		}
+		vendorName, repoName, version = packageRef.Vendor, packageRef.Name, release.Version
+		// the version is kept separately so that the package can be resolved again
+		remoteURL = packageRef.Vendor + "/" + packageRef.Name
+		absTargetSubFolder, err = checkInstallTarget(ctx, filepath.Join(targetPath, vendorName, repoName))
+		if err != nil {
+			return nil, err
+		}
+		utilities.Formatter.Info("Resolved " + packageRef.String() + " to version " + release.Version)
+		if release.Git != "" {
+			ref = release.Ref
+			commit, err = requests.NewGitClient().CloneRef(ctx, release.Git, stagingFolder, ref)
+			if err == nil {
+				err = release.VerifyCommit(commit)
+			}
+		} else {
+			err = packageRegistry.Fetch(release, stagingFolder)
+		}
+		if err != nil {
+			return nil, err
+		}
 	default:
-		utilities.Formatter.Error("Unsupported Remote Type.")
+		return nil, utilities.NewError(utilities.RequestError, "unsupported remote address: "+remoteURL, nil)
+	}
+	manifest, err := readManifest(stagingFolder)
+	if err != nil {
+		return nil, err
+	}
+	source, err := parsePackageSource(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+	dependencies, err := installDependencies(ctx, manifest, append(append([]string{}, chain...), source.identity), installs)
+	if err != nil {
+		return nil, err
+	}
+	if err = downloadPretrained(ctx, vendorName+"/"+repoName, stagingFolder); err != nil {
+		return nil, err
+	}
+	tx, err := database.NewDefaultDB().Tx(ctx)
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot start transaction", err)
+	}
+	installedRepository, err := tx.Repository.Create().
+		SetName(repoName).
+		SetLocalpath(absTargetSubFolder).
+		SetVendor(vendorName).
+		SetUID(imageID).
+		SetRemoteURL(remoteURL).
+		SetVersion(version).
+		SetRef(ref).
+		SetCommit(commit).
+		SetStatus("Source Code Installed").
+		AddDependencies(dependencies...).
+		Save(ctx)
+	if err != nil {
+		tx.Rollback()
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot save new package to database", err)
+	}
+	if err = syncSolvers(ctx, tx.Client(), installedRepository, manifest); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = moveIntoPlace(stagingFolder, absTargetSubFolder); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		os.RemoveAll(absTargetSubFolder)
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot save new package to database", err)
+	}
+	installedRepository = installedRepository.Unwrap()
+	installs.packages = append(installs.packages, installedRepository)
+	utilities.Formatter.Info(installedRepository.Name + " installed successfully")
+	return installedRepository, nil
+}
+
+// packageSource identifies a package independently of its version or ref
+type packageSource struct {
+	vendor string
+	name   string
+	// identity is [vendor]/[name] for registry packages and [host]/[path] for git packages
+	identity string
+}
+
+// parsePackageSource parses registry and git addresses, with or without a version or ref
+func parsePackageSource(address string) (packageSource, error) {
+	if packageRef, ok := registry.ParsePackageRef(address); ok && !requests.IsLocalPath(address) {
+		return packageSource{vendor: packageRef.Vendor, name: packageRef.Name, identity: packageRef.Vendor + "/" + packageRef.Name}, nil
+	}
+	address, _ = requests.SplitRef(address)
+	gitURL, err := requests.ParseGitURL(address)
+	if err != nil {
+		return packageSource{}, err
+	}
+	return packageSource{vendor: gitURL.Vendor(), name: gitURL.Name(), identity: strings.ToLower(gitURL.Host) + "/" + gitURL.Path}, nil
+}
+
+// installDependencies returns the dependencies declared in the manifest, missing
+// ones are installed. chain lists the identities of the packages whose
+// dependencies are being installed, including the current one, and is used
+// to detect cycles. Newly installed packages are recorded in installs.
+func installDependencies(ctx context.Context, manifest *configuration.Manifest, chain []string, installs *installLog) ([]*ent.Repository, error) {
+	var dependencies []*ent.Repository
+	for _, dependency := range manifest.Dependencies {
+		source, err := parsePackageSource(dependency.Package)
+		if err != nil {
+			return nil, err
+		}
+		for _, parent := range chain {
+			if parent == source.identity {
+				return nil, utilities.NewError(utilities.PackageError, "dependency cycle: "+strings.Join(append(chain, source.identity), " -> "), nil)
+			}
+		}
+		var constraint *registry.Constraint
+		if isRegistryDependency(dependency) {
+			constraint, _ = registry.ParseConstraint(dependency.Version)
+		}
+		existing, err := installedPackage(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			if constraint != nil && !constraint.Check(existing.Version) {
+				return nil, utilities.NewError(utilities.PackageError, dependency.Package+" is installed at version "+existing.Version+", which does not satisfy "+dependency.Version, nil)
+			}
+			dependencies = append(dependencies, existing)
+			continue
+		}
+		address := dependency.Package
+		if constraint != nil {
+			packageRef, _ := registry.ParsePackageRef(dependency.Package)
+			release, err := registry.Default().ResolveConstraint(packageRef, constraint)
+			if err != nil {
+				return nil, err
+			}
+			address += "@" + release.Version
+		} else if dependency.Ref != "" {
+			address += "@" + dependency.Ref
+		}
+		utilities.Formatter.Info("Installing dependency " + address + " of " + chain[len(chain)-1])
+		installed, err := pullPackage(ctx, address, chain, installs)
+		if err != nil {
+			return nil, err
+		}
+		dependencies = append(dependencies, installed)
+	}
+	return dependencies, nil
+}
+
+// installedPackage returns the installed package with the same source, or nil
+// if there is none. Packages are installed in [vendor]/[name], so a package
+// with the same name from another source is an error.
+func installedPackage(ctx context.Context, source packageSource) (*ent.Repository, error) {
+	repos, err := database.NewDefaultDB().Repository.Query().Where(entRepository.Vendor(source.vendor), entRepository.Name(source.name)).All(ctx)
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch repository "+source.identity, err)
+	}
+	for _, repo := range repos {
+		if repoSource, err := parsePackageSource(repo.RemoteURL); err == nil && repoSource.identity == source.identity {
+			return repo, nil
+		}
+	}
+	if len(repos) > 0 {
+		return nil, utilities.NewError(utilities.PackageError, "cannot install "+source.identity+", "+source.vendor+"/"+source.name+" is already installed from "+repos[0].RemoteURL, nil)
+	}
+	return nil, nil
+}
+
+// checkInstallTarget returns the absolute path of the target folder,
+// or an error if another package is already installed there
+func checkInstallTarget(ctx context.Context, targetFolder string) (string, error) {
+	absTargetFolder, err := filepath.Abs(targetFolder)
+	if err != nil {
+		return "", utilities.NewError(utilities.RequestError, "invalid target folder "+targetFolder, err)
+	}
+	exists, err := database.NewDefaultDB().Repository.Query().Where(entRepository.Localpath(absTargetFolder)).Exist(ctx)
+	if err != nil {
+		return "", utilities.NewError(utilities.DatabaseError, "cannot fetch repositories", err)
+	}
+	if exists {
+		return "", utilities.NewError(utilities.RequestError, "a package is already installed at "+absTargetFolder+", remove or upgrade it instead", nil)
 	}
-	tomlString, err := utilities.ReadFileContent(filepath.Join(installedRepository.Localpath, "aid.toml"))
-	utilities.ReportError(err, "cannot parse aid.toml file")
-	packageConfig := configuration.LoadPackageFromConfig(tomlString)
-	for _, solver := range packageConfig.Solvers {
-		_, err = database.NewDefaultDB().Solver.Create().SetName(solver.Name).SetRepository(installedRepository).SetClass(solver.Class).SetStatus("Code Installed").Save(context.Background())
-		utilities.ReportError(err, "cannot save new solver to database")
+	// leftovers of a package that is not in the database anymore
+	if err = os.RemoveAll(absTargetFolder); err != nil {
+		return "", utilities.NewError(utilities.PackageError, "cannot delete the folder "+absTargetFolder, err)
+	}
+	return absTargetFolder, nil
+}
+
+// downloadPretrained links the models listed in pretrained.toml, if any,
+// from the artifact cache into the package. Missing models are downloaded in parallel.
+func downloadPretrained(ctx context.Context, packageName string, packageFolder string) error {
+	pretrainedTomlPath := filepath.Join(packageFolder, "pretrained.toml")
+	if !utilities.IsFileExists(pretrainedTomlPath) {
+		return nil
+	}
+	pretrainedTomlString, err := utilities.ReadFileContent(pretrainedTomlPath)
+	if err != nil {
+		return utilities.NewError(utilities.PackageError, "cannot read pretrained.toml file", err)
 	}
-	pretrainedTomlString, err := utilities.ReadFileContent(filepath.Join(installedRepository.Localpath, "pretrained.toml"))
 	pretraineds := configuration.LoadPretrainedsFromConfig(pretrainedTomlString)
+	if err := pretraineds.Validate(); err != nil {
+		return utilities.NewError(utilities.PackageError, "invalid pretrained.toml", err)
+	}
+	var artifacts []cargo.ArtifactRequest
 	for _, pretrained := range pretraineds.Models {
-		err = utilities.Download(pretrained.URL, filepath.Join(installedRepository.Localpath, "pretrained"))
+		artifact := cargo.ArtifactRequest{
+			Name:   pretrained.Name,
+			URL:    pretrained.URL,
+			Sha256: pretrained.Sha256,
+			Size:   pretrained.Size,
+		}
+		if location, err := url.Parse(pretrained.URL); err == nil && location.Scheme == "" && !filepath.IsAbs(pretrained.URL) {
+			// models shipped with the package are read from its folder, the
+			// url is made unique so that it is not mistaken for the same path in another package
+			artifact.URL = filepath.Join(packageFolder, pretrained.URL)
+			source := &system.FileSource{Folders: []string{packageFolder}}
+			if artifact.Open, err = source.Opener(&url.URL{Scheme: "file", Path: filepath.ToSlash(artifact.URL)}); err != nil {
+				return err
+			}
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	// the artifacts must not be pruned before they are linked
+	lock, err := cargo.ShareCache()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	digests, err := cargo.CacheArtifacts(ctx, packageName, artifacts, system.NewDefaultConfig().DownloadConcurrency)
+	if err != nil {
+		return err
+	}
+	for idx, artifact := range artifacts {
+		filename := filepath.Base(strings.SplitN(artifact.URL, "?", 2)[0])
+		if err = cargo.LinkArtifact(digests[idx], filepath.Join(packageFolder, "pretrained", filename)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveIntoPlace moves the staged package folder to its target folder
+func moveIntoPlace(stagingFolder string, targetFolder string) error {
+	if err := os.MkdirAll(filepath.Dir(targetFolder), os.ModePerm); err != nil {
+		return utilities.NewError(utilities.PackageError, "cannot create the folder "+filepath.Dir(targetFolder), err)
+	}
+	if err := os.Rename(stagingFolder, targetFolder); err != nil {
+		return utilities.NewError(utilities.PackageError, "cannot move the package to "+targetFolder, err)
+	}
+	return nil
+}
+
+// syncSolvers makes the solvers of the repository in the database match the manifest,
+// new solvers are created, changed ones are updated and removed ones are deleted.
+func syncSolvers(ctx context.Context, client *ent.Client, repository *ent.Repository, manifest *configuration.Manifest) error {
+	existing, err := repository.QuerySolvers().All(ctx)
+	if err != nil {
+		return utilities.NewError(utilities.DatabaseError, "cannot fetch solvers of "+repository.Name, err)
+	}
+	existingSolvers := make(map[string]*ent.Solver)
+	for _, solver := range existing {
+		existingSolvers[solver.Name] = solver
+	}
+	for _, solver := range manifest.Solvers {
+		if existingSolver, ok := existingSolvers[solver.Name]; ok {
+			delete(existingSolvers, solver.Name)
+			if existingSolver.Class == solver.Class {
+				continue
+			}
+			_, err = existingSolver.Update().SetClass(solver.Class).Save(ctx)
+		} else {
+			_, err = client.Solver.Create().SetName(solver.Name).SetRepository(repository).SetClass(solver.Class).SetStatus("Code Installed").Save(ctx)
+		}
 		if err != nil {
-			utilities.Formatter.Error("Cannot Download " + pretrained.URL + ": " + err.Error())
+			return utilities.NewError(utilities.DatabaseError, "cannot save solver "+solver.Name+" to database", err)
 		}
 	}
-	if err == nil {
-		utilities.Formatter.Info(installedRepository.Name + " installed successfully")
+	for _, removed := range existingSolvers {
+		if err = client.Solver.DeleteOne(removed).Exec(ctx); err != nil {
+			return utilities.NewError(utilities.DatabaseError, "cannot remove solver "+removed.Name+" from database", err)
+		}
 	}
+	return nil
 }
@@ -0,0 +1,101 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package workflow
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	entContainer "github.com/autoai-org/aid/ent/generated/container"
+	entJob "github.com/autoai-org/aid/ent/generated/job"
+	entSystemLog "github.com/autoai-org/aid/ent/generated/systemlog"
+	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/jobs/state"
+	"github.com/autoai-org/aid/internal/runtime/docker"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// SystemLogID refers to the log file of aid itself
+const SystemLogID = "system"
+
+// logPollInterval is how often log files are checked for new contents
+const logPollInterval = 500 * time.Millisecond
+
+// logIdleTimeout is how long the log of a build without a job is followed
+// after it stopped growing
+const logIdleTimeout = time.Minute
+
+// StreamLogs writes the logs of a container, a build (or a job) or the system into w.
+// If follow is true, it keeps streaming until ctx is cancelled, the container
+// stops or the job writing the log finishes.
+func StreamLogs(ctx context.Context, id string, follow bool, w io.Writer) error {
+	client := database.NewDefaultDB()
+	if id == SystemLogID {
+		return streamLogFile(ctx, filepath.Join(utilities.GetBasePath(), "logs", "system"), follow, nil, w)
+	}
+	if containerEnt, err := client.Container.Query().Where(entContainer.UID(id)).First(context.Background()); err == nil {
+		return docker.StreamContainerLogs(ctx, containerEnt.UID, follow, w)
+	}
+	if buildLog, err := client.SystemLog.Query().Where(entSystemLog.Title(id)).First(context.Background()); err == nil {
+		return streamLogFile(ctx, buildLog.Filepath, follow, jobFinished(buildLog.Filepath), w)
+	}
+	if job, err := client.Job.Query().Where(entJob.UID(id)).First(context.Background()); err == nil {
+		return streamLogFile(ctx, job.Logpath, follow, jobFinished(job.Logpath), w)
+	}
+	return utilities.NewError(utilities.RequestError, "cannot find container, build or job "+id, nil)
+}
+
+// jobFinished reports whether the job writing to logPath has finished. Builds
+// run outside of a job have no state, their logs are considered finished once
+// they have not grown for logIdleTimeout.
+func jobFinished(logPath string) func() bool {
+	idle := logIdle(logPath)
+	return func() bool {
+		job, err := database.NewDefaultDB().Job.Query().Where(entJob.Logpath(logPath)).First(context.Background())
+		if ent.IsNotFound(err) {
+			return idle()
+		}
+		return err == nil && state.IsFinished(job.State)
+	}
+}
+
+// logIdle reports whether logPath has not grown for logIdleTimeout
+func logIdle(logPath string) func() bool {
+	size, changed := int64(-1), time.Now()
+	return func() bool {
+		if info, err := os.Stat(logPath); err == nil && info.Size() != size {
+			size, changed = info.Size(), time.Now()
+		}
+		return time.Since(changed) > logIdleTimeout
+	}
+}
+
+func streamLogFile(ctx context.Context, logPath string, follow bool, done func() bool, w io.Writer) error {
+	if !follow {
+		content, err := utilities.ReadFileContent(logPath)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, content)
+		return err
+	}
+	var writeErr error
+	followCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err := utilities.FollowFile(followCtx, logPath, logPollInterval, done, func(data []byte) {
+		if _, writeErr = w.Write(data); writeErr != nil {
+			cancel()
+		}
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package workflow
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/autoai-org/aid/internal/configuration"
+	"github.com/autoai-org/aid/internal/runtime/registry"
+	"github.com/autoai-org/aid/internal/runtime/requests"
+)
+
+// ValidatePackage checks aid.toml in the package folder, including the
+// addresses and versions of its dependencies, see configuration.ValidatePackage
+func ValidatePackage(packageFolder string) ([]configuration.ManifestIssue, error) {
+	manifest, issues, err := configuration.ValidatePackage(packageFolder)
+	if err != nil || manifest == nil {
+		return issues, err
+	}
+	return append(issues, dependencyIssues(manifest)...), nil
+}
+
+// readManifest reads aid.toml in the package folder and returns an error
+// listing all issues, including those of the dependencies, if it is invalid
+func readManifest(packageFolder string) (*configuration.Manifest, error) {
+	manifest, err := configuration.ReadManifest(packageFolder)
+	if err != nil {
+		return nil, err
+	}
+	if err := configuration.IssuesError(filepath.Join(packageFolder, configuration.ManifestFile), dependencyIssues(manifest)); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// isRegistryDependency returns true if the dependency is a registry package
+func isRegistryDependency(dependency configuration.DependencyManifest) bool {
+	_, ok := registry.ParsePackageRef(dependency.Package)
+	return ok && !requests.IsLocalPath(dependency.Package)
+}
+
+// dependencyIssues checks that dependencies are registry packages with a
+// version constraint or git packages with a ref
+func dependencyIssues(manifest *configuration.Manifest) []configuration.ManifestIssue {
+	var issues []configuration.ManifestIssue
+	report := func(key string, field string, message string) {
+		issues = append(issues, configuration.ManifestIssue{Line: manifest.Line(key), Field: field, Message: message})
+	}
+	for idx, dependency := range manifest.Dependencies {
+		table := "dependencies." + strconv.Itoa(idx)
+		field := fmt.Sprintf("dependencies[%d]", idx)
+		switch {
+		case dependency.Package == "":
+			// reported by configuration.ParseManifest
+		case isRegistryDependency(dependency):
+			if ref, _ := registry.ParsePackageRef(dependency.Package); ref.Version != "" {
+				report(table+".package", field+".package", "use version to constrain the version")
+			}
+			if _, err := registry.ParseConstraint(dependency.Version); err != nil {
+				report(table+".version", field+".version", fmt.Sprintf("invalid version constraint %q", dependency.Version))
+			}
+			if dependency.Ref != "" {
+				report(table+".ref", field+".ref", "is only supported by git packages")
+			}
+		case requests.IsGitURL(dependency.Package):
+			if dependency.Version != "" {
+				report(table+".version", field+".version", "is only supported by registry packages, use ref instead")
+			}
+		default:
+			report(table+".package", field+".package", fmt.Sprintf("should be [vendor]/[name] or a git address, got %q", dependency.Package))
+		}
+	}
+	return issues
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package workflow
+
+import (
+	"testing"
+
+	"github.com/autoai-org/aid/internal/configuration"
+)
+
+func TestDependencyIssues(t *testing.T) {
+	manifest, issues := configuration.ParseManifest(`[package]
+name = "demo"
+
+[[solvers]]
+name = "detector"
+class = "demo/solver/Detector"
+
+[[dependencies]]
+package = "aidmodels/base"
+version = ">=1.2, <2"
+
+[[dependencies]]
+package = "https://github.com/aidmodels/utils"
+ref = "v1.0.0"
+
+[[dependencies]]
+package = "../local"
+
+[[dependencies]]
+package = "aidmodels/base@1.0.0"
+version = "latest"
+ref = "main"
+
+[[dependencies]]
+package = "git@github.com:aidmodels/utils.git"
+version = "^1.0"
+
+[[dependencies]]
+package = "not a package"
+`)
+	if manifest == nil || len(issues) > 0 {
+		t.Fatalf("ParseManifest = %v, %v", manifest, issues)
+	}
+	want := []configuration.ManifestIssue{
+		{Line: 20, Field: "dependencies[3].package"},
+		{Line: 21, Field: "dependencies[3].version"},
+		{Line: 22, Field: "dependencies[3].ref"},
+		{Line: 26, Field: "dependencies[4].version"},
+		{Line: 29, Field: "dependencies[5].package"},
+	}
+	got := dependencyIssues(manifest)
+	if len(got) != len(want) {
+		t.Fatalf("dependencyIssues = %v, want %d issues", got, len(want))
+	}
+	for idx := range want {
+		if got[idx].Line != want[idx].Line || got[idx].Field != want[idx].Field {
+			t.Errorf("dependencyIssues[%d] = %v, want line %d of %s", idx, got[idx], want[idx].Line, want[idx].Field)
+		}
+	}
+}
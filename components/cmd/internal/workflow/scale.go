@@ -0,0 +1,114 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package workflow
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	entContainer "github.com/autoai-org/aid/ent/generated/container"
+	entImage "github.com/autoai-org/aid/ent/generated/image"
+	entRepository "github.com/autoai-org/aid/ent/generated/repository"
+	entSolver "github.com/autoai-org/aid/ent/generated/solver"
+	"github.com/autoai-org/aid/internal/configuration"
+	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/runtime/docker"
+	"github.com/autoai-org/aid/internal/runtime/requests"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// ScaleSolver runs replicas containers of the solver, whose image must have
+// been built. Stopped containers of the image are started first, then new
+// ones are created with the [runtime] settings of the package on free host
+// ports. Surplus containers are stopped and removed, unhealthy ones first.
+// With 0 replicas, the stopped containers are removed as well. The running
+// containers are returned.
+func ScaleSolver(vendorName string, packageName string, solverName string, replicas int) ([]*ent.Container, error) {
+	solver := vendorName + "/" + packageName + "/" + solverName
+	if replicas < 0 {
+		return nil, utilities.NewError(utilities.RequestError, "invalid number of replicas "+strconv.Itoa(replicas), nil)
+	}
+	ctx := context.Background()
+	image, err := database.NewDefaultDB().Image.Query().
+		Where(entImage.HasSolverWith(
+			entSolver.Name(solverName),
+			entSolver.HasRepositoryWith(entRepository.Vendor(vendorName), entRepository.Name(packageName)),
+		)).
+		First(ctx)
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch the image of "+solver+", build it with aid build "+solver, err)
+	}
+	containers, err := imageContainers(image)
+	if err != nil {
+		return nil, err
+	}
+	var running, stopped []*ent.Container
+	for _, container := range containers {
+		if container.Running {
+			running = append(running, container)
+		} else {
+			stopped = append(stopped, container)
+		}
+	}
+	// the oldest healthy replicas are kept
+	sort.SliceStable(running, func(i, j int) bool {
+		return running[i].Health != requests.HealthUnhealthy && running[j].Health == requests.HealthUnhealthy
+	})
+	for len(running) > replicas {
+		surplus := running[len(running)-1]
+		if err := docker.Stop(surplus.UID); err != nil {
+			return nil, err
+		}
+		if err := docker.RemoveContainer(surplus.UID); err != nil {
+			return nil, err
+		}
+		running = running[:len(running)-1]
+	}
+	if replicas == 0 {
+		for _, container := range stopped {
+			if err := docker.RemoveContainer(container.UID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for started := len(running); started < replicas; started++ {
+		var container *ent.Container
+		if len(stopped) > 0 {
+			container, stopped = stopped[0], stopped[1:]
+		} else if container, err = CreateContainer(image.UID, "", "", configuration.RuntimeManifest{}); err != nil {
+			return nil, err
+		}
+		if err := StartContainer(container.UID); err != nil {
+			return nil, err
+		}
+		utilities.Formatter.Info("Started replica " + container.UID + " of " + solver + " on port " + container.Port)
+	}
+	containers, err = imageContainers(image)
+	if err != nil {
+		return nil, err
+	}
+	running = running[:0]
+	for _, container := range containers {
+		if container.Running {
+			running = append(running, container)
+		}
+	}
+	return running, nil
+}
+
+// imageContainers returns the containers of the image, the oldest first
+func imageContainers(image *ent.Image) ([]*ent.Container, error) {
+	containers, err := database.NewDefaultDB().Container.Query().
+		Where(entContainer.HasImageWith(entImage.ID(image.ID))).
+		Order(ent.Asc(entContainer.FieldCreatedAt)).
+		All(context.Background())
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch the containers of image "+image.UID, err)
+	}
+	return containers, nil
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package workflow
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	ent "github.com/autoai-org/aid/ent/generated"
+	entRepository "github.com/autoai-org/aid/ent/generated/repository"
+	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/runtime/registry"
+	"github.com/autoai-org/aid/internal/runtime/requests"
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// PackageStatus compares an installed package with its remote
+type PackageStatus struct {
+	Repository *ent.Repository `json:"repository"`
+	// Latest is the latest commit (git) or version (registry)
+	Latest string `json:"latest"`
+	// Pinned is true if the package is installed from a tag or a commit
+	Pinned   bool `json:"pinned"`
+	Outdated bool `json:"outdated"`
+	// Error is set if the remote cannot be reached, the status is unknown then
+	Error string `json:"error,omitempty"`
+}
+
+// UpgradePackage downloads the latest source code of the package and re-syncs
+// its solvers from aid.toml. If ref is not empty, the package is switched to
+// this ref (git) or version (registry). The pretrained models of the new
+// revision are fetched, the ones already downloaded are kept.
+func UpgradePackage(ctx context.Context, packageID string, ref string) (upgraded *ent.Repository, err error) {
+	repo, err := database.NewDefaultDB().Repository.Query().Where(entRepository.UID(packageID)).First(ctx)
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch repository "+packageID+" from database", err)
+	}
+	stagingFolder := filepath.Join(utilities.GetFolder("temp"), "upgrade-"+repo.UID)
+	os.RemoveAll(stagingFolder)
+	defer os.RemoveAll(stagingFolder)
+	version, commit := repo.Version, ""
+	if packageRef, ok := registry.ParsePackageRef(repo.RemoteURL); ok {
+		packageRef.Version = ref
+		packageRegistry := registry.Default()
+		release, err := packageRegistry.Resolve(packageRef)
+		if err != nil {
+			return repo, err
+		}
+		if release.Version == repo.Version {
+			utilities.Formatter.Info(repo.Vendor + "/" + repo.Name + " is already at version " + repo.Version)
+			return repo, nil
+		}
+		version, ref = release.Version, release.Ref
+		if release.Git != "" {
+			commit, err = requests.NewGitClient().CloneRef(ctx, release.Git, stagingFolder, release.Ref)
+			if err == nil {
+				err = release.VerifyCommit(commit)
+			}
+		} else {
+			err = packageRegistry.Fetch(release, stagingFolder)
+		}
+		if err != nil {
+			return repo, err
+		}
+	} else {
+		if ref == "" {
+			ref = repo.Ref
+		}
+		commit, err = requests.NewGitClient().CloneRef(ctx, repo.RemoteURL, stagingFolder, ref)
+		if err != nil {
+			return repo, err
+		}
+		if commit == repo.Commit {
+			utilities.Formatter.Info(repo.Vendor + "/" + repo.Name + " is already at commit " + commit)
+			return repo, nil
+		}
+	}
+	manifest, err := readManifest(stagingFolder)
+	if err != nil {
+		return repo, err
+	}
+	source, err := parsePackageSource(repo.RemoteURL)
+	if err != nil {
+		return repo, err
+	}
+	installs := &installLog{}
+	defer func() {
+		if err != nil {
+			installs.rollback(0)
+		}
+	}()
+	dependencies, err := installDependencies(ctx, manifest, []string{source.identity}, installs)
+	if err != nil {
+		return repo, err
+	}
+	if err = downloadPretrained(ctx, repo.Vendor+"/"+repo.Name, stagingFolder); err != nil {
+		return repo, err
+	}
+	tx, err := database.NewDefaultDB().Tx(ctx)
+	if err != nil {
+		return repo, utilities.NewError(utilities.DatabaseError, "cannot start transaction", err)
+	}
+	upgraded, err = tx.Repository.UpdateOne(repo).SetVersion(version).
+		SetRef(ref).
+		SetCommit(commit).
+		ClearDependencies().
+		AddDependencies(dependencies...).
+		Save(ctx)
+	if err != nil {
+		tx.Rollback()
+		return repo, utilities.NewError(utilities.DatabaseError, "cannot update repository "+packageID, err)
+	}
+	if err = syncSolvers(ctx, tx.Client(), upgraded, manifest); err != nil {
+		tx.Rollback()
+		return repo, err
+	}
+	previousFolder := stagingFolder + "-previous"
+	if err = swapFolders(repo.Localpath, stagingFolder, previousFolder); err != nil {
+		tx.Rollback()
+		return repo, err
+	}
+	if err = tx.Commit(); err != nil {
+		if restoreErr := swapFolders(repo.Localpath, previousFolder, stagingFolder); restoreErr != nil {
+			utilities.Formatter.Error("Cannot restore " + repo.Localpath + ": " + restoreErr.Error())
+		}
+		return repo, utilities.NewError(utilities.DatabaseError, "cannot update repository "+packageID, err)
+	}
+	if err := keepPretrained(filepath.Join(previousFolder, "pretrained"), filepath.Join(repo.Localpath, "pretrained")); err != nil {
+		utilities.Formatter.Warn("Cannot keep pretrained models of " + repo.Localpath + ": " + err.Error())
+	}
+	os.RemoveAll(previousFolder)
+	utilities.Formatter.Info(repo.Vendor + "/" + repo.Name + " upgraded successfully")
+	return upgraded.Unwrap(), nil
+}
+
+// OutdatedPackages checks every installed package against its remote, a
+// remote that cannot be reached is reported in the status of its package
+func OutdatedPackages() ([]PackageStatus, error) {
+	repos, err := database.NewDefaultDB().Repository.Query().All(context.Background())
+	if err != nil {
+		return nil, utilities.NewError(utilities.DatabaseError, "cannot fetch repositories", err)
+	}
+	var statuses []PackageStatus
+	for _, repo := range repos {
+		status := PackageStatus{Repository: repo}
+		if packageRef, ok := registry.ParsePackageRef(repo.RemoteURL); ok {
+			release, err := registry.Default().Resolve(packageRef)
+			if err != nil {
+				status.Error = err.Error()
+				statuses = append(statuses, status)
+				continue
+			}
+			status.Latest = release.Version
+			status.Outdated = release.Version != repo.Version
+		} else {
+			latest, found, err := requests.NewGitClient().RemoteBranchHash(repo.RemoteURL, repo.Ref)
+			if err != nil {
+				status.Error = err.Error()
+				statuses = append(statuses, status)
+				continue
+			}
+			status.Pinned = !found
+			status.Latest = latest
+			status.Outdated = found && latest != repo.Commit
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// keepPretrained moves the pretrained models of the previous revision that
+// the new revision does not provide into its folder
+func keepPretrained(previousFolder string, currentFolder string) error {
+	entries, err := ioutil.ReadDir(previousFolder)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(currentFolder, os.ModePerm); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		target := filepath.Join(currentFolder, entry.Name())
+		if _, err := os.Lstat(target); err == nil {
+			continue
+		}
+		if err := os.Rename(filepath.Join(previousFolder, entry.Name()), target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// swapFolders moves currentFolder to previousFolder and newFolder to currentFolder,
+// currentFolder is left untouched if the swap fails
+func swapFolders(currentFolder string, newFolder string, previousFolder string) error {
+	os.RemoveAll(previousFolder)
+	if err := os.Rename(currentFolder, previousFolder); err != nil {
+		return utilities.NewError(utilities.PackageError, "cannot move "+currentFolder, err)
+	}
+	if err := os.Rename(newFolder, currentFolder); err != nil {
+		os.Rename(previousFolder, currentFolder)
+		return utilities.NewError(utilities.PackageError, "cannot move the new source code to "+currentFolder, err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"os"
+
+	"github.com/autoai-org/aid/internal/utilities"
+)
+
+// exitOnError prints the error and exits with the code of its category,
+// as documented in docs/specs/error-code.md. With --output json or yaml
+// the error is printed as {"error": [message], "code": [code]} to stdout.
+func exitOnError(err error, message string) {
+	if err == nil {
+		return
+	}
+	code := int(utilities.Category(err))
+	if structuredOutput() {
+		printRecord(map[string]interface{}{"error": message + ": " + err.Error(), "code": code})
+	} else {
+		utilities.Formatter.Error(message + ": " + err.Error())
+	}
+	os.Exit(code)
+}
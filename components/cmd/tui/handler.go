@@ -5,28 +5,161 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	markdown "github.com/MichaelMure/go-term-markdown"
+	entContainer "github.com/autoai-org/aid/ent/generated/container"
 	entRepository "github.com/autoai-org/aid/ent/generated/repository"
+	"github.com/autoai-org/aid/internal/configuration"
 	"github.com/autoai-org/aid/internal/daemon"
 	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/jobs"
 	"github.com/autoai-org/aid/internal/runtime/cargo"
 	"github.com/autoai-org/aid/internal/runtime/docker"
 	"github.com/autoai-org/aid/internal/runtime/requests"
 	"github.com/autoai-org/aid/internal/utilities"
 	"github.com/autoai-org/aid/internal/workflow"
+	"github.com/dustin/go-humanize"
 	"github.com/urfave/cli/v2"
 )
 
-func installPackage(remoteURL string) {
-	workflow.PullPackageSource(remoteURL)
+func installPackage(remoteURL string, detach bool) {
+	if detach {
+		// the daemon runs in another working directory
+		if requests.IsLocalPath(remoteURL) {
+			absPath, err := filepath.Abs(remoteURL)
+			exitOnError(err, "Cannot install "+remoteURL)
+			remoteURL = absPath
+		}
+		queueJob(jobs.KindInstall, remoteURL)
+		return
+	}
+	unsubscribe := cargo.SubscribeDownloads(newDownloadRenderer(utilities.LogWriter).render)
+	repo, err := workflow.PullPackageSource(remoteURL)
+	unsubscribe()
+	exitOnError(err, "Cannot install "+remoteURL)
+	printResult(repo)
+}
+
+func upgradePackage(packageID string, ref string) {
+	repo, err := workflow.UpgradePackage(context.Background(), packageID, ref)
+	exitOnError(err, "Cannot upgrade "+packageID)
+	printResult(repo)
+}
+
+func validatePackage(packageFolder string) {
+	if packageFolder == "" {
+		packageFolder = "."
+	}
+	issues, err := workflow.ValidatePackage(packageFolder)
+	exitOnError(err, "Cannot validate "+packageFolder)
+	if structuredOutput() {
+		valid := true
+		for _, issue := range issues {
+			valid = valid && issue.Warning
+		}
+		if issues == nil {
+			issues = []configuration.ManifestIssue{}
+		}
+		printRecord(map[string]interface{}{"valid": valid, "issues": issues})
+		if !valid {
+			os.Exit(int(utilities.PackageError))
+		}
+		return
+	}
+	invalid := false
+	for _, issue := range issues {
+		if issue.Warning {
+			utilities.Formatter.Warn(issue.String())
+		} else {
+			invalid = true
+			utilities.Formatter.Error(issue.String())
+		}
+	}
+	if invalid {
+		exitOnError(utilities.NewError(utilities.PackageError, "the package in "+packageFolder+" is invalid", nil), "Validation failed")
+	}
+	utilities.Formatter.Info("The package in " + packageFolder + " is valid")
+}
+
+// doctor compares the containers and images in the database with docker
+func doctor(fix bool) {
+	drifts, err := docker.Reconcile(fix)
+	exitOnError(err, "Cannot compare the database with docker")
+	if len(drifts) == 0 {
+		utilities.Formatter.Info("The database is in sync with docker")
+	}
+	problems := &listing{columns: []column{
+		{title: "Entity"},
+		{title: "Unique ID"},
+		{title: "Kind"},
+		{title: "Problem"},
+		{title: "Fixed"},
+	}}
+	unfixed := 0
+	for _, drift := range drifts {
+		if !drift.Fixed {
+			unfixed++
+		}
+		problems.add(drift, drift.Entity, drift.UID, drift.Kind, drift.Message, fmt.Sprint(drift.Fixed))
+	}
+	if len(drifts) > 0 || structuredOutput() {
+		problems.print()
+	}
+	if len(drifts) == 0 {
+		return
+	}
+	if !fix {
+		utilities.Formatter.Warn("Run aid doctor --fix to update the database")
+	} else if unfixed > 0 {
+		utilities.Formatter.Warn(fmt.Sprintf("%d problems cannot be fixed automatically, e.g. orphans whose package is not installed", unfixed))
+	}
+}
+
+func pruneCache() {
+	pruned, err := cargo.PruneArtifacts()
+	var freed int64
+	for _, artifact := range pruned {
+		freed += artifact.Size
+		utilities.Formatter.Info("Removed " + artifact.Name + " (" + artifact.Digest + ")")
+	}
+	exitOnError(err, "Cannot prune cache")
+	utilities.Formatter.Info(fmt.Sprintf("Removed %d artifacts, %s freed", len(pruned), humanize.Bytes(uint64(freed))))
+	if pruned == nil {
+		pruned = []cargo.CachedArtifact{}
+	}
+	printResult(pruned)
+}
+
+func verifyCache() {
+	corrupted, err := cargo.VerifyArtifacts()
+	exitOnError(err, "Cannot verify cache")
+	if structuredOutput() {
+		if corrupted == nil {
+			corrupted = []cargo.CachedArtifact{}
+		}
+		printRecord(map[string]interface{}{"intact": len(corrupted) == 0, "corrupted": corrupted})
+		if len(corrupted) > 0 {
+			os.Exit(int(utilities.PackageError))
+		}
+		return
+	}
+	for _, artifact := range corrupted {
+		utilities.Formatter.Error(artifact.Name + " (" + artifact.Digest + ") is corrupted, used by " + strings.Join(artifact.UsedBy, ", "))
+	}
+	if len(corrupted) > 0 {
+		exitOnError(utilities.NewError(utilities.PackageError, fmt.Sprintf("%d corrupted artifacts, run aid cache prune after removing the packages using them", len(corrupted)), nil), "Verification failed")
+	}
+	utilities.Formatter.Info("All cached artifacts are intact")
 }
 
 // listObject
-func listObject(objectName string) {
-	listEntity(objectName)
+func listObject(objectName string, filter string, sortField string) {
+	listEntity(objectName, filter, sortField)
 }
 
 // startServer
@@ -34,60 +167,154 @@ func startServer(port string) {
 	daemon.RunServer(port)
 }
 
-func buildImage(buildContext string) {
+func buildImage(buildContext string, detach bool) {
 	buildInfo := strings.Split(buildContext, "/")
-	workflow.BuildDockerImage(buildInfo[0], buildInfo[1], buildInfo[2])
+	if len(buildInfo) != 3 {
+		exitOnError(utilities.NewError(utilities.RequestError, "expected [vendor]/[package]/[solver], got "+buildContext, nil), "Cannot build image")
+	}
+	if detach {
+		queueJob(jobs.KindBuild, buildContext)
+		return
+	}
+	image, err := workflow.BuildDockerImage(buildInfo[0], buildInfo[1], buildInfo[2])
+	exitOnError(err, "Cannot build image")
+	printResult(image)
+}
+
+func scaleSolver(solver string, replicas int) {
+	solverInfo := strings.Split(solver, "/")
+	if len(solverInfo) != 3 {
+		exitOnError(utilities.NewError(utilities.RequestError, "expected [vendor]/[package]/[solver], got "+solver, nil), "Cannot scale solver")
+	}
+	containers, err := workflow.ScaleSolver(solverInfo[0], solverInfo[1], solverInfo[2], replicas)
+	exitOnError(err, "Cannot scale solver")
+	utilities.Formatter.Info(fmt.Sprintf("%s has %d running replicas", solver, len(containers)))
+	list := &listing{columns: []column{
+		{title: "Unique ID"},
+		{title: "Name"},
+		{title: "Port"},
+		{title: "Health"},
+	}}
+	for _, container := range containers {
+		list.add(container, container.UID, container.Name, container.Port, container.Health)
+	}
+	list.print()
+}
+
+func createContainer(imageID string, hostPort string, name string, runtime configuration.RuntimeManifest) {
+	container, err := workflow.CreateContainer(imageID, hostPort, name, runtime)
+	exitOnError(err, "Cannot create container")
+	printResult(container)
+}
+
+// runtimeFromFlags reads the container settings given to aid create
+func runtimeFromFlags(c *cli.Context) configuration.RuntimeManifest {
+	runtime := configuration.RuntimeManifest{
+		Volumes:     c.StringSlice("volume"),
+		Memory:      c.String("memory"),
+		CPUs:        c.Float64("cpus"),
+		Restart:     c.String("restart"),
+		BindAddress: c.String("bind"),
+	}
+	for _, env := range c.StringSlice("env") {
+		key, value, err := configuration.ParseEnv(env)
+		if err != nil {
+			exitOnError(utilities.NewError(utilities.RequestError, "invalid environment variable", err), "Cannot create container")
+		}
+		if runtime.Env == nil {
+			runtime.Env = make(map[string]string)
+		}
+		runtime.Env[key] = value
+	}
+	return runtime
 }
 
-func createContainer(imageID string, hostPort string) {
-	if hostPort == "" {
-		utilities.Formatter.Error("Hostport is not given... Aborted")
-		os.Exit(4)
+func startContainer(containerID string, wait bool, timeout time.Duration) {
+	exitOnError(workflow.StartContainer(containerID), "Cannot start container")
+	if wait {
+		utilities.Formatter.Info("Waiting for " + containerID + " to be ready...")
+		exitOnError(docker.WaitHealthy(containerID, timeout), "Container is not ready")
+		utilities.Formatter.Info("Container " + containerID + " is ready")
 	}
-	workflow.CreateContainer(imageID, hostPort)
+	printContainer(containerID)
 }
 
-func startContainer(containerID string) {
-	workflow.StartContainer(containerID)
+func stopContainer(containerID string) {
+	exitOnError(docker.Stop(containerID), "Cannot stop container")
+	printContainer(containerID)
 }
 
-func infer(containerID string, args cli.Args) {
+// printContainer prints the container with --output json or yaml
+func printContainer(containerID string) {
+	if !structuredOutput() {
+		return
+	}
+	container, err := database.NewDefaultDB().Container.Query().Where(entContainer.UID(containerID)).First(context.Background())
+	if err != nil {
+		exitOnError(utilities.NewError(utilities.DatabaseError, "cannot fetch container "+containerID, err), "Cannot print container")
+	}
+	printRecord(container)
+}
+
+func infer(containerID string, args cli.Args, filePath string) {
 	params := make(map[string]string)
 	for _, param := range args.Tail() {
-		kv := strings.Split(param, "=")
+		// values may contain =, e.g. base64 strings
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			exitOnError(utilities.NewError(utilities.RequestError, "expected [key]=[value], got "+param, nil), "Cannot perform inference")
+		}
 		params[kv[0]] = kv[1]
 	}
-	resp, err := requests.NewHTTPClient().Infer(containerID, params)
-	if err != nil {
-		utilities.ReportError(err, "Cannot handle requests from "+containerID)
-		os.Exit(6)
+	resp, err := requests.NewHTTPClient().Infer(containerID, params, filePath)
+	exitOnError(err, "Cannot perform inference")
+	if structuredOutput() {
+		// solvers answer with json, anything else is wrapped
+		var result interface{}
+		if err := resp.JSON(&result); err != nil {
+			result = map[string]string{"result": resp.String()}
+		}
+		printRecord(result)
+		return
 	}
 	utilities.Formatter.Info("Inference successfully returned:")
 	fmt.Println(resp.String())
 }
 
+func batchInfer(containerID string, inputPath string, outputPath string) {
+	if outputPath == "" {
+		outputPath = strings.TrimSuffix(inputPath, filepath.Ext(inputPath)) + ".results.csv"
+	}
+	utilities.Formatter.Info("Performing inference on every row of " + inputPath + "...")
+	exitOnError(requests.NewHTTPClient().BatchInfer(containerID, inputPath, outputPath), "Cannot perform batch inference")
+	utilities.Formatter.Info("The results have been written to " + outputPath)
+	printResult(map[string]string{"output": outputPath})
+}
+
 func help(packageID string) {
 	repo, err := database.NewDefaultDB().Repository.Query().Where(entRepository.UID(packageID)).First(context.Background())
 	if err != nil {
-		utilities.Formatter.Error("Cannot fetch repository " + fmt.Sprint(packageID) + ", Aborted")
-		os.Exit(3)
+		exitOnError(utilities.NewError(utilities.DatabaseError, "cannot fetch repository "+packageID, err), "Cannot show help")
 	}
 	readmeFilePath := filepath.Join(repo.Localpath, "README.md")
 	readmeFile, err := ioutil.ReadFile(readmeFilePath)
 	if err != nil {
-		utilities.Formatter.Error("Cannot read the readme file from " + readmeFilePath)
-		os.Exit(6)
+		exitOnError(utilities.NewError(utilities.PackageError, "cannot read the readme file from "+readmeFilePath, err), "Cannot show help")
 	}
 	result := markdown.Render(string(readmeFile), 80, 6)
 	separator := markdown.Render(string("---"), 80, 6)
 	fmt.Println(string(result))
 	fmt.Println(string(separator))
-	for _, solver := range repo.Edges.Solvers {
+	solvers, err := repo.QuerySolvers().All(context.Background())
+	if err != nil {
+		exitOnError(utilities.NewError(utilities.DatabaseError, "cannot fetch the solvers of "+packageID, err), "Cannot show help")
+	}
+	for _, solver := range solvers {
 		fmt.Println(solver.Name)
 	}
 }
 
-func remove(entity string, identifier string) error {
+func remove(entity string, identifier string) {
 	var err error
 	switch entity {
 	case "package":
@@ -96,10 +323,61 @@ func remove(entity string, identifier string) error {
 		err = docker.RemoveContainer(identifier)
 	case "image":
 		err = docker.RemoveImage(identifier)
+	default:
+		err = utilities.NewError(utilities.RequestError, "unsupported entity "+entity, nil)
 	}
+	exitOnError(err, "Cannot remove "+entity+" with the id "+identifier)
+}
+
+func showJob(jobID string) {
+	job, err := jobs.Get(jobID)
 	if err != nil {
-		utilities.Formatter.Error("Cannot remove " + entity + " with the id " + identifier + ": " + err.Error())
-		os.Exit(3)
+		exitOnError(utilities.NewError(utilities.DatabaseError, "cannot fetch job "+jobID, err), "Cannot show job")
+	}
+	if structuredOutput() {
+		printRecord(job)
+		return
+	}
+	fmt.Println("Unique ID:  " + job.UID)
+	fmt.Println("Kind:       " + job.Kind)
+	fmt.Println("Target:     " + job.Target)
+	fmt.Println("State:      " + job.State)
+	fmt.Println("CreatedAt:  " + job.CreatedAt.Local().Format("2006/01/02 15:04:05"))
+	if job.StartedAt != nil {
+		fmt.Println("StartedAt:  " + job.StartedAt.Local().Format("2006/01/02 15:04:05"))
+	}
+	if job.FinishedAt != nil {
+		fmt.Println("FinishedAt: " + job.FinishedAt.Local().Format("2006/01/02 15:04:05"))
 	}
-	return err
+	if job.Error != "" {
+		fmt.Println("Error:      " + job.Error)
+	}
+	fmt.Println("Log:        " + job.Logpath)
+}
+
+// queueJob saves a queued job, the workers of the daemon pick it up
+func queueJob(kind string, target string) {
+	job, err := jobs.Create(kind, target)
+	exitOnError(err, "Cannot queue "+kind+" of "+target)
+	utilities.Formatter.Info("Job " + job.UID + " queued, it runs once the daemon (aid up) picks it up. See aid logs -f " + job.UID)
+	printResult(job)
+}
+
+func cancelJob(jobID string) {
+	job, err := jobs.Cancel(jobID)
+	exitOnError(err, "Cannot cancel job "+jobID)
+	utilities.Formatter.Info("Job " + job.UID + " cancelled")
+	printResult(job)
+}
+
+func showLogs(id string, follow bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-quit
+		cancel()
+	}()
+	exitOnError(workflow.StreamLogs(ctx, id, follow, os.Stdout), "Cannot read logs of "+id)
 }
@@ -0,0 +1,130 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexeyco/simpletable"
+	"github.com/autoai-org/aid/internal/utilities"
+	"github.com/autoai-org/aid/internal/workflow"
+	"github.com/dustin/go-humanize"
+)
+
+func inspect(id string) {
+	details, err := workflow.Inspect(id)
+	exitOnError(err, "Cannot inspect "+id)
+	if structuredOutput() {
+		printRecord(details)
+		return
+	}
+	repo := details.Package
+	fmt.Println("Package:    " + repo.Vendor + "/" + repo.Name)
+	fmt.Println("Unique ID:  " + repo.UID)
+	fmt.Println("Status:     " + repo.Status)
+	if repo.Version != "" {
+		fmt.Println("Version:    " + repo.Version)
+	}
+	if repo.Ref != "" {
+		fmt.Println("Ref:        " + repo.Ref)
+	}
+	if repo.Commit != "" {
+		fmt.Println("Commit:     " + repo.Commit)
+	}
+	fmt.Println("Remote URL: " + repo.RemoteURL)
+	fmt.Println("Local Path: " + repo.Localpath)
+	fmt.Println("CreatedAt:  " + formatTime(&repo.CreatedAt))
+
+	solvers := &listing{columns: []column{
+		{title: "Name"},
+		{title: "Class"},
+		{title: "Status"},
+		{title: "Image"},
+		{title: "Build ID"},
+	}}
+	containers := &listing{columns: []column{
+		{title: "Unique ID"},
+		{title: "Solver"},
+		{title: "Name"},
+		{title: "Port"},
+		{title: "State"},
+		{title: "Health"},
+		{title: "StartedAt", align: simpletable.AlignCenter},
+	}}
+	var stateError string
+	for _, solver := range details.Solvers {
+		image := ""
+		if solver.Image != nil {
+			image = solver.Image.UID
+		}
+		solvers.add(solver, solver.Solver.Name, solver.Solver.Class, solver.Solver.Status, image, solver.BuildID)
+		for _, container := range solver.Containers {
+			state, started := "unknown", ""
+			if container.StateError != "" {
+				stateError = container.StateError
+			}
+			if container.State != nil {
+				state, started = container.State.Status, formatDockerTime(container.State.StartedAt)
+				if !container.State.Running && container.State.FinishedAt != "" {
+					state += fmt.Sprintf(" (%d)", container.State.ExitCode)
+				}
+			}
+			containers.add(container, container.Container.UID, solver.Solver.Name, container.Container.Name,
+				container.Container.Port, state, container.Container.Health, started)
+		}
+	}
+	fmt.Println("\nSolvers:")
+	solvers.print()
+	if len(containers.rows) > 0 {
+		fmt.Println("\nContainers:")
+		containers.print()
+		if stateError != "" {
+			utilities.Formatter.Warn("The states of some containers are unknown, " + stateError)
+		}
+	}
+
+	if len(details.Artifacts) > 0 {
+		artifacts := &listing{columns: []column{
+			{title: "Digest", align: simpletable.AlignCenter},
+			{title: "Name"},
+			{title: "Size", align: simpletable.AlignRight},
+			{title: "Used By"},
+		}}
+		for _, artifact := range details.Artifacts {
+			artifacts.add(artifact, shortHash(artifact.Digest), artifact.Name, humanize.Bytes(uint64(artifact.Size)),
+				strings.Join(artifact.UsedBy, ", "))
+		}
+		fmt.Println("\nPretrained Artifacts:")
+		artifacts.print()
+	}
+
+	if len(details.Builds) > 0 {
+		builds := &listing{columns: []column{
+			{title: "Job ID"},
+			{title: "Target"},
+			{title: "State"},
+			{title: "CreatedAt", align: simpletable.AlignCenter},
+			{title: "FinishedAt", align: simpletable.AlignCenter},
+			{title: "Error", wide: true},
+		}}
+		for _, job := range details.Builds {
+			builds.add(job, job.UID, job.Target, job.State, formatTime(&job.CreatedAt), formatTime(job.FinishedAt), job.Error)
+		}
+		fmt.Println("\nRecent Builds:")
+		builds.print()
+	}
+}
+
+// formatDockerTime formats the RFC 3339 times of docker like formatTime
+func formatDockerTime(value string) string {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return value
+	}
+	return formatTime(&t)
+}
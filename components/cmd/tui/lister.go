@@ -3,11 +3,16 @@ package main
 import (
 	"context"
 	"fmt"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/alexeyco/simpletable"
 	"github.com/autoai-org/aid/internal/database"
+	"github.com/autoai-org/aid/internal/jobs"
+	"github.com/autoai-org/aid/internal/runtime/cargo"
 	"github.com/autoai-org/aid/internal/utilities"
+	"github.com/autoai-org/aid/internal/workflow"
+	"github.com/dustin/go-humanize"
 )
 
 func baseList(headers simpletable.Header, items [][]*simpletable.Cell) {
@@ -17,94 +22,199 @@ func baseList(headers simpletable.Header, items [][]*simpletable.Cell) {
 	table.Println()
 }
 
-func listPackage() {
+// formatTime formats times in tables, nil and zero times are left empty
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("2006/01/02 15:04:05")
+}
+
+func listPackage() *listing {
 	repos, err := database.NewDefaultDB().Repository.
 		Query().
 		All(context.Background())
 	if err != nil {
-		utilities.Formatter.Error("Cannot fetch repositories: " + err.Error())
-		os.Exit(3)
+		exitOnError(utilities.NewError(utilities.DatabaseError, "cannot fetch repositories", err), "Cannot list packages")
 	}
-	headers := simpletable.Header{
-		Cells: []*simpletable.Cell{
-			{Align: simpletable.AlignCenter, Text: "#"},
-			{Align: simpletable.AlignCenter, Text: "Unique ID"},
-			{Align: simpletable.AlignCenter, Text: "Vendor"},
-			{Align: simpletable.AlignCenter, Text: "Name"},
-			{Align: simpletable.AlignCenter, Text: "Status"},
-			{Align: simpletable.AlignCenter, Text: "CreatedAt"},
-		},
+	packages := &listing{columns: []column{
+		{title: "Unique ID", align: simpletable.AlignCenter},
+		{title: "Vendor"},
+		{title: "Name"},
+		{title: "Status"},
+		{title: "CreatedAt", align: simpletable.AlignCenter},
+		{title: "Version", wide: true},
+		{title: "Ref", wide: true},
+		{title: "Commit", wide: true},
+		{title: "Remote URL", wide: true},
+		{title: "Local Path", wide: true},
+	}}
+	for _, repo := range repos {
+		packages.add(repo, repo.UID, repo.Vendor, repo.Name, repo.Status, formatTime(&repo.CreatedAt),
+			repo.Version, repo.Ref, shortHash(repo.Commit), repo.RemoteURL, repo.Localpath)
 	}
-	var rows [][]*simpletable.Cell
-	for idx, repo := range repos {
-		r := []*simpletable.Cell{
-			{Align: simpletable.AlignCenter, Text: fmt.Sprint(idx + 1)},
-			{Align: simpletable.AlignCenter, Text: repo.UID},
-			{Text: repo.Vendor},
-			{Text: repo.Name},
-			{Text: repo.Status},
-			{Align: simpletable.AlignCenter, Text: repo.CreatedAt.Local().Format("2006/01/02 15:04:05")},
+	return packages
+}
+
+func listOutdated() {
+	statuses, err := workflow.OutdatedPackages()
+	exitOnError(err, "Cannot check packages")
+	outdated := &listing{columns: []column{
+		{title: "Unique ID", align: simpletable.AlignCenter},
+		{title: "Package"},
+		{title: "Ref"},
+		{title: "Current"},
+		{title: "Latest"},
+		{title: "Status"},
+		{title: "Error", wide: true},
+	}}
+	unknown := 0
+	for _, status := range statuses {
+		repo := status.Repository
+		current, latest := repo.Version, status.Latest
+		if current == "" {
+			current, latest = shortHash(repo.Commit), shortHash(latest)
+		}
+		state := "up to date"
+		if status.Error != "" {
+			state = "unknown"
+			unknown++
+		} else if status.Pinned {
+			state = "pinned"
+		} else if status.Outdated {
+			state = "outdated"
 		}
-		rows = append(rows, r)
+		outdated.add(status, repo.UID, repo.Vendor+"/"+repo.Name, repo.Ref, current, latest, state, status.Error)
+	}
+	outdated.print()
+	if unknown > 0 && !structuredOutput() && outputFormat != outputWide {
+		utilities.Formatter.Warn(fmt.Sprintf("The remotes of %d packages cannot be reached, see --output wide", unknown))
 	}
-	baseList(headers, rows)
 }
 
-func listImages() {
-	images, err := database.NewDefaultDB().Image.Query().All(context.Background())
-	utilities.ReportError(err, "cannot fetch content: images")
-	headers := simpletable.Header{
-		Cells: []*simpletable.Cell{
-			{Align: simpletable.AlignCenter, Text: "#"},
-			{Align: simpletable.AlignCenter, Text: "Unique ID"},
-			{Align: simpletable.AlignCenter, Text: "Name"},
-		},
+func shortHash(commit string) string {
+	if len(commit) > 10 {
+		return commit[0:10]
 	}
-	var rows [][]*simpletable.Cell
-	for idx, image := range images {
-		r := []*simpletable.Cell{
-			{Align: simpletable.AlignCenter, Text: fmt.Sprint(idx + 1)},
-			{Text: image.UID},
-			{Text: image.Title},
+	return commit
+}
+
+func listCache() {
+	artifacts, err := cargo.ListArtifacts()
+	exitOnError(err, "Cannot list cached artifacts")
+	cache := &listing{columns: []column{
+		{title: "Digest", align: simpletable.AlignCenter},
+		{title: "Name"},
+		{title: "Size", align: simpletable.AlignRight},
+		{title: "Used By"},
+		{title: "AddedAt", align: simpletable.AlignCenter},
+		{title: "URL", wide: true},
+	}}
+	var unused int64
+	for _, artifact := range artifacts {
+		if len(artifact.UsedBy) == 0 {
+			unused += artifact.Size
 		}
-		rows = append(rows, r)
+		cache.add(artifact, shortHash(artifact.Digest), artifact.Name, humanize.Bytes(uint64(artifact.Size)),
+			strings.Join(artifact.UsedBy, ", "), formatTime(&artifact.AddedAt), artifact.URL)
+	}
+	cache.print()
+	if !structuredOutput() {
+		fmt.Printf("Total: %.2f MB, unused: %s\n", cargo.CacheSizeMB(), humanize.Bytes(uint64(unused)))
 	}
-	baseList(headers, rows)
 }
 
-func listContainers() {
-	containers, err := database.NewDefaultDB().Container.Query().All(context.Background())
-	utilities.ReportError(err, "cannot fetch content: containers")
-	headers := simpletable.Header{
-		Cells: []*simpletable.Cell{
-			{Align: simpletable.AlignCenter, Text: "#"},
-			{Align: simpletable.AlignCenter, Text: "Unique ID"},
-			{Align: simpletable.AlignCenter, Text: "Port"},
-			{Align: simpletable.AlignCenter, Text: "Running"},
-		},
+func listImages() *listing {
+	images, err := database.NewDefaultDB().Image.Query().All(context.Background())
+	if err != nil {
+		exitOnError(utilities.NewError(utilities.DatabaseError, "cannot fetch images", err), "Cannot list images")
 	}
-	var rows [][]*simpletable.Cell
-	for idx, container := range containers {
-		r := []*simpletable.Cell{
-			{Align: simpletable.AlignCenter, Text: fmt.Sprint(idx + 1)},
-			{Text: container.UID},
-			{Text: container.Port},
-			{Text: fmt.Sprint(container.Running)},
+	list := &listing{columns: []column{
+		{title: "Unique ID"},
+		{title: "Name"},
+		{title: "CreatedAt", align: simpletable.AlignCenter, wide: true},
+	}}
+	for _, image := range images {
+		list.add(image, image.UID, image.Title, formatTime(&image.CreatedAt))
+	}
+	return list
+}
+
+func listContainers() *listing {
+	containers, err := database.NewDefaultDB().Container.Query().WithImage().All(context.Background())
+	if err != nil {
+		exitOnError(utilities.NewError(utilities.DatabaseError, "cannot fetch containers", err), "Cannot list containers")
+	}
+	list := &listing{columns: []column{
+		{title: "Unique ID"},
+		{title: "Name"},
+		{title: "Port"},
+		{title: "Running"},
+		{title: "Health"},
+		{title: "Image", wide: true},
+		{title: "Bind Address", wide: true},
+		{title: "Memory", align: simpletable.AlignRight, wide: true},
+		{title: "CPUs", align: simpletable.AlignRight, wide: true},
+		{title: "Restart", wide: true},
+		{title: "CreatedAt", align: simpletable.AlignCenter, wide: true},
+	}}
+	// the health is the one saved by the monitor of the daemon, aid inspect refreshes it
+	for _, container := range containers {
+		image, memory, cpus := "", "", ""
+		if container.Edges.Image != nil {
+			image = container.Edges.Image.Title
+		}
+		if container.Memory > 0 {
+			memory = humanize.IBytes(uint64(container.Memory))
 		}
-		rows = append(rows, r)
+		if container.Cpus > 0 {
+			cpus = fmt.Sprint(container.Cpus)
+		}
+		list.add(container, container.UID, container.Name, container.Port, fmt.Sprint(container.Running), container.Health,
+			image, container.BindAddress, memory, cpus, container.Restart, formatTime(&container.CreatedAt))
+	}
+	return list
+}
+
+func listJobs() *listing {
+	jobList, err := jobs.List()
+	if err != nil {
+		exitOnError(utilities.NewError(utilities.DatabaseError, "cannot fetch jobs", err), "Cannot list jobs")
+	}
+	list := &listing{columns: []column{
+		{title: "Unique ID"},
+		{title: "Kind"},
+		{title: "Target"},
+		{title: "State"},
+		{title: "CreatedAt", align: simpletable.AlignCenter},
+		{title: "StartedAt", align: simpletable.AlignCenter, wide: true},
+		{title: "FinishedAt", align: simpletable.AlignCenter, wide: true},
+		{title: "Error", wide: true},
+	}}
+	for _, job := range jobList {
+		list.add(job, job.UID, job.Kind, job.Target, job.State, formatTime(&job.CreatedAt),
+			formatTime(job.StartedAt), formatTime(job.FinishedAt), job.Error)
 	}
-	baseList(headers, rows)
+	return list
 }
 
-func listEntity(entityName string) {
+// listEntity prints the entities whose json fields match filter, e.g.
+// vendor=foo,running=true, ordered by the json field sortField
+func listEntity(entityName string, filter string, sortField string) {
+	var list *listing
 	switch entityName {
 	case "packages":
-		listPackage()
+		list = listPackage()
 	case "images":
-		listImages()
+		list = listImages()
 	case "containers":
-		listContainers()
+		list = listContainers()
+	case "jobs":
+		list = listJobs()
 	default:
-		utilities.Formatter.Error("Unsupported Entity Name")
+		exitOnError(utilities.NewError(utilities.RequestError, "unsupported entity "+entityName+", expected packages, images, containers or jobs", nil), "Cannot list "+entityName)
 	}
+	exitOnError(list.filter(filter), "Cannot list "+entityName)
+	list.sort(sortField)
+	list.print()
 }
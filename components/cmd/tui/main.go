@@ -9,8 +9,8 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"time"
 
-	"github.com/autoai-org/aid/internal/runtime/docker"
 	"github.com/autoai-org/aid/internal/system"
 	"github.com/autoai-org/aid/internal/utilities"
 	"github.com/urfave/cli/v2"
@@ -40,6 +40,15 @@ func main() {
 				Usage:       "Enable detailed logs",
 				Destination: &utilities.Verbose,
 			},
+			&cli.StringFlag{
+				Name:        "output",
+				Value:       outputTable,
+				Usage:       "Output format: table, wide, json or yaml",
+				Destination: &outputFormat,
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return setupOutput()
 		},
 		Action: func(c *cli.Context) error {
 			return nil
@@ -47,54 +56,199 @@ func main() {
 		Commands: []*cli.Command{
 			{
 				Name:     "install",
-				Usage:    "aid install [remote address]",
+				Usage:    "aid install [--detach] [remote address[@ref] | vendor/name@version]",
+				Category: "packages",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "detach",
+						Aliases: []string{"d"},
+						Usage:   "Queue the installation as a job of the daemon (aid up) instead of waiting for it",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					installPackage(c.Args().Get(0), c.Bool("detach"))
+					return nil
+				},
+			},
+			{
+				Name:     "upgrade",
+				Usage:    "aid upgrade [--ref branch|tag|commit] [Package Unique ID]",
+				Category: "packages",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "ref",
+						Usage: "Switch the package to this branch, tag, commit or registry version",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					upgradePackage(c.Args().Get(0), c.String("ref"))
+					return nil
+				},
+			},
+			{
+				Name:     "outdated",
+				Usage:    "aid outdated",
+				Category: "packages",
+				Action: func(c *cli.Context) error {
+					listOutdated()
+					return nil
+				},
+			},
+			{
+				Name:     "validate",
+				Usage:    "aid validate [Package Folder]",
 				Category: "packages",
 				Action: func(c *cli.Context) error {
-					installPackage(c.Args().Get(0))
+					validatePackage(c.Args().Get(0))
 					return nil
 				},
 			},
 			{
 				Name:     "build",
-				Usage:    "aid build [vendor]/[package]/[solver]",
+				Usage:    "aid build [--detach] [vendor]/[package]/[solver]",
 				Category: "packages",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "detach",
+						Aliases: []string{"d"},
+						Usage:   "Queue the build as a job of the daemon (aid up) instead of waiting for it",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					buildImage(c.Args().Get(0))
+					buildImage(c.Args().Get(0), c.Bool("detach"))
 					return nil
 				},
 			},
 			{
 				Name:     "create",
-				Usage:    "aid create [Image Unique ID] [Host Port]",
+				Usage:    "aid create [--name name] [--env KEY=VALUE] [--volume host:container[:ro]] [Image Unique ID] [Host Port, a free one by default]",
 				Category: "packages",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "Name of the container, a unique one is generated by default",
+					},
+					&cli.StringSliceFlag{
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Set an environment variable, KEY=VALUE",
+					},
+					&cli.StringSliceFlag{
+						Name:    "volume",
+						Aliases: []string{"v"},
+						Usage:   "Mount a host path, relative paths are relative to the package folder, e.g. pretrained:/models:ro",
+					},
+					&cli.StringFlag{
+						Name:    "memory",
+						Aliases: []string{"m"},
+						Usage:   "Memory limit, e.g. 512m or 2g",
+					},
+					&cli.Float64Flag{
+						Name:  "cpus",
+						Usage: "Number of CPUs, e.g. 1.5",
+					},
+					&cli.StringFlag{
+						Name:  "restart",
+						Usage: "Restart policy: no, always, unless-stopped or on-failure[:max retries]",
+					},
+					&cli.StringFlag{
+						Name:  "bind",
+						Usage: "Host address to publish the port on, 0.0.0.0 by default",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					createContainer(c.Args().Get(0), c.Args().Get(1))
+					createContainer(c.Args().Get(0), c.Args().Get(1), c.String("name"), runtimeFromFlags(c))
 					return nil
 				},
 			},
 			{
 				Name:     "start",
-				Usage:    "aid start [Container Unique ID]",
+				Usage:    "aid start [--wait [--timeout 2m]] [Container Unique ID]",
+				Category: "packages",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "wait",
+						Value: false,
+						Usage: "Wait until the solver is ready to serve requests",
+					},
+					&cli.DurationFlag{
+						Name:  "timeout",
+						Value: 2 * time.Minute,
+						Usage: "Maximum time to wait for the solver",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					startContainer(c.Args().Get(0), c.Bool("wait"), c.Duration("timeout"))
+					return nil
+				},
+			},
+			{
+				Name:     "scale",
+				Usage:    "aid scale --replicas N [vendor]/[package]/[solver]",
 				Category: "packages",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:     "replicas",
+						Usage:    "Number of running containers, surplus ones are stopped and removed",
+						Required: true,
+					},
+				},
 				Action: func(c *cli.Context) error {
-					startContainer(c.Args().Get(0))
+					scaleSolver(c.Args().Get(0), c.Int("replicas"))
 					return nil
 				},
 			},
 			{
 				Name:  "infer",
-				Usage: "Perform Inference",
+				Usage: "aid infer [--file path | --batch input.csv [--out-file output.csv]] [Container Unique ID] [key=value]...",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "file",
+						Aliases: []string{"f"},
+						Usage:   "Upload a file, e.g. an image, along with the parameters",
+					},
+					&cli.StringFlag{
+						Name:  "batch",
+						Usage: "Perform the inference on every row of a csv file",
+					},
+					&cli.StringFlag{
+						Name:  "out-file",
+						Usage: "Where to write the results of --batch, [input].results.csv by default",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					infer(c.Args().Get(0), c.Args())
+					if c.String("batch") != "" {
+						batchInfer(c.Args().Get(0), c.String("batch"), c.String("out-file"))
+						return nil
+					}
+					infer(c.Args().Get(0), c.Args(), c.String("file"))
 					return nil
 				},
 			},
 			{
 				Name:    "list",
 				Aliases: []string{"ls"},
-				Usage:   "List Entities",
+				Usage:   "aid ls [--filter field=value,...] [--sort [-]field] [packages | images | containers | jobs]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "filter",
+						Usage: "Only list entities whose json fields have these values, e.g. vendor=aidmodels,running=true",
+					},
+					&cli.StringFlag{
+						Name:  "sort",
+						Usage: "Sort by a json field, e.g. created_at, prefixed with - for descending order",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					listObject(c.Args().Get(0))
+					listObject(c.Args().Get(0), c.String("filter"), c.String("sort"))
+					return nil
+				},
+			},
+			{
+				Name:  "inspect",
+				Usage: "aid inspect [Package/Image/Container Unique ID | vendor/package | vendor/package/solver]",
+				Action: func(c *cli.Context) error {
+					inspect(c.Args().Get(0))
 					return nil
 				},
 			},
@@ -110,7 +264,7 @@ func main() {
 				Name:  "stop",
 				Usage: "Stop a running container",
 				Action: func(c *cli.Context) error {
-					docker.Stop(c.Args().Get(0))
+					stopContainer(c.Args().Get(0))
 					return nil
 				},
 			},
@@ -122,6 +276,102 @@ func main() {
 					return nil
 				},
 			},
+			{
+				Name:     "jobs",
+				Usage:    "Manage background jobs",
+				Category: "daemon",
+				Subcommands: []*cli.Command{
+					{
+						Name:    "list",
+						Aliases: []string{"ls"},
+						Usage:   "aid jobs ls",
+						Action: func(c *cli.Context) error {
+							listJobs().print()
+							return nil
+						},
+					},
+					{
+						Name:  "show",
+						Usage: "aid jobs show [Job Unique ID]",
+						Action: func(c *cli.Context) error {
+							showJob(c.Args().Get(0))
+							return nil
+						},
+					},
+					{
+						Name:  "cancel",
+						Usage: "aid jobs cancel [Job Unique ID]",
+						Action: func(c *cli.Context) error {
+							cancelJob(c.Args().Get(0))
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:     "cache",
+				Usage:    "Manage cached pretrained models",
+				Category: "packages",
+				Subcommands: []*cli.Command{
+					{
+						Name:    "list",
+						Aliases: []string{"ls"},
+						Usage:   "aid cache ls",
+						Action: func(c *cli.Context) error {
+							listCache()
+							return nil
+						},
+					},
+					{
+						Name:  "prune",
+						Usage: "aid cache prune",
+						Action: func(c *cli.Context) error {
+							pruneCache()
+							return nil
+						},
+					},
+					{
+						Name:  "verify",
+						Usage: "aid cache verify",
+						Action: func(c *cli.Context) error {
+							verifyCache()
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "logs",
+				Usage: "aid logs [-f] [Container/Build/Job Unique ID | system]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "follow",
+						Aliases: []string{"f"},
+						Value:   false,
+						Usage:   "Keep streaming new logs",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					showLogs(c.Args().Get(0), c.Bool("follow"))
+					return nil
+				},
+			},
+			{
+				Name:     "doctor",
+				Usage:    "aid doctor [--fix]",
+				Category: "daemon",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "fix",
+						Value: false,
+						Usage: "Update the database to match docker",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					doctor(c.Bool("fix"))
+					return nil
+				},
+			},
 			{
 				Name:     "up",
 				Usage:    "Server Up",
@@ -136,5 +386,5 @@ func main() {
 	sort.Sort(cli.FlagsByName(app.Flags))
 	sort.Sort(cli.CommandsByName(app.Commands))
 	err := app.Run(os.Args)
-	utilities.ReportError(err, "AID Starting Error")
+	exitOnError(err, "AID Starting Error")
 }
@@ -0,0 +1,257 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexeyco/simpletable"
+	"github.com/autoai-org/aid/internal/utilities"
+	"gopkg.in/yaml.v2"
+)
+
+// The formats of the global --output flag, wide is a table with more columns
+const (
+	outputTable = "table"
+	outputWide  = "wide"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// outputFormat is set by --output
+var outputFormat = outputTable
+
+// structuredOutput is true if the output is meant to be parsed by scripts
+func structuredOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// setupOutput checks --output. With json and yaml the logs are written to
+// stderr, so that stdout only holds the result or the error.
+func setupOutput() error {
+	switch outputFormat {
+	case outputTable, outputWide:
+	case outputJSON, outputYAML:
+		utilities.LogWriter = os.Stderr
+	default:
+		format := outputFormat
+		outputFormat = outputTable
+		return utilities.NewError(utilities.RequestError, "unsupported output "+format+", expected table, wide, json or yaml", nil)
+	}
+	return nil
+}
+
+// printRecord prints v as json or yaml. Both use the json field names, so v
+// is converted to json first.
+func printRecord(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		exitOnError(utilities.NewError(utilities.UnknownError, "cannot encode the output", err), "Cannot print the result")
+	}
+	if outputFormat == outputYAML {
+		if data, err = jsonToYAML(data); err != nil {
+			exitOnError(utilities.NewError(utilities.UnknownError, "cannot encode the output", err), "Cannot print the result")
+		}
+		fmt.Print(string(data))
+		return
+	}
+	fmt.Println(string(data))
+}
+
+// printResult prints the result of a command with --output json or yaml,
+// in tables the logs of the command are enough
+func printResult(v interface{}) {
+	if structuredOutput() {
+		printRecord(v)
+	}
+}
+
+func jsonToYAML(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(yamlValue(document))
+}
+
+// yamlValue converts json numbers, which would be quoted by yaml, to ints or floats
+func yamlValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case json.Number:
+		if number, err := value.Int64(); err == nil {
+			return number
+		}
+		number, _ := value.Float64()
+		return number
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = yamlValue(item)
+		}
+	case []interface{}:
+		for idx, item := range value {
+			value[idx] = yamlValue(item)
+		}
+	}
+	return value
+}
+
+// column is a column of a listing
+type column struct {
+	title string
+	align int
+	// wide columns are only shown by --output wide
+	wide bool
+}
+
+// listing is the result of a list command, it is printed as a table or as
+// a json or yaml array of its records
+type listing struct {
+	columns []column
+	rows    []listingRow
+}
+
+type listingRow struct {
+	cells  []string
+	record interface{}
+	// fields is the record as json, used to filter and sort
+	fields map[string]interface{}
+}
+
+// add appends a record with one cell per column
+func (l *listing) add(record interface{}, cells ...string) {
+	l.rows = append(l.rows, listingRow{cells: cells, record: record})
+}
+
+// filter keeps the records whose json fields have the given values, e.g.
+// vendor=foo,running=true. Missing fields are treated as zero values.
+func (l *listing) filter(expression string) error {
+	if expression == "" {
+		return nil
+	}
+	conditions := make(map[string]string)
+	for _, condition := range strings.Split(expression, ",") {
+		kv := strings.SplitN(condition, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return utilities.NewError(utilities.RequestError, "invalid filter "+condition+", expected [field]=[value]", nil)
+		}
+		conditions[kv[0]] = kv[1]
+	}
+	var rows []listingRow
+	for _, row := range l.rows {
+		matched := true
+		for key, expected := range conditions {
+			if !matchField(row.field(key), expected) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			rows = append(rows, row)
+		}
+	}
+	l.rows = rows
+	return nil
+}
+
+// sort orders the records by a json field, in descending order if the
+// field is prefixed with -
+func (l *listing) sort(field string) {
+	if field == "" {
+		return
+	}
+	descending := strings.HasPrefix(field, "-")
+	field = strings.TrimPrefix(field, "-")
+	sort.SliceStable(l.rows, func(i, j int) bool {
+		if descending {
+			return lessField(l.rows[j].field(field), l.rows[i].field(field))
+		}
+		return lessField(l.rows[i].field(field), l.rows[j].field(field))
+	})
+}
+
+func (row *listingRow) field(key string) interface{} {
+	if row.fields == nil {
+		row.fields = make(map[string]interface{})
+		data, _ := json.Marshal(row.record)
+		json.Unmarshal(data, &row.fields)
+	}
+	return row.fields[key]
+}
+
+// matchField compares a json value with a value of --filter, empty values are
+// left out of the json of entities, so missing fields match zero values
+func matchField(value interface{}, expected string) bool {
+	if value == nil {
+		return expected == "" || expected == "false" || expected == "0"
+	}
+	return fieldString(value) == expected
+}
+
+// fieldString formats a json value as it is written in --filter
+func fieldString(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// lessField compares numbers by value and everything else by its text,
+// times are compared correctly as they are written in RFC 3339
+func lessField(a interface{}, b interface{}) bool {
+	numberA, okA := a.(float64)
+	numberB, okB := b.(float64)
+	if okA && okB {
+		return numberA < numberB
+	}
+	return fieldString(a) < fieldString(b)
+}
+
+// print writes the listing in the format of --output
+func (l *listing) print() {
+	if structuredOutput() {
+		records := make([]interface{}, 0, len(l.rows))
+		for _, row := range l.rows {
+			records = append(records, row.record)
+		}
+		printRecord(records)
+		return
+	}
+	headers := simpletable.Header{
+		Cells: []*simpletable.Cell{{Align: simpletable.AlignCenter, Text: "#"}},
+	}
+	for _, column := range l.columns {
+		if !column.wide || outputFormat == outputWide {
+			headers.Cells = append(headers.Cells, &simpletable.Cell{Align: simpletable.AlignCenter, Text: column.title})
+		}
+	}
+	var rows [][]*simpletable.Cell
+	for idx, row := range l.rows {
+		cells := []*simpletable.Cell{{Align: simpletable.AlignCenter, Text: fmt.Sprint(idx + 1)}}
+		for cellIdx, column := range l.columns {
+			if !column.wide || outputFormat == outputWide {
+				cells = append(cells, &simpletable.Cell{Align: column.align, Text: row.cells[cellIdx]})
+			}
+		}
+		rows = append(rows, cells)
+	}
+	baseList(headers, rows)
+}
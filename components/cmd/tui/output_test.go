@@ -0,0 +1,112 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+type testRecord struct {
+	Name    string  `json:"name,omitempty"`
+	Vendor  string  `json:"vendor,omitempty"`
+	Running bool    `json:"running,omitempty"`
+	Port    int     `json:"port,omitempty"`
+	Load    float64 `json:"load,omitempty"`
+	Created string  `json:"created_at,omitempty"`
+}
+
+func testListing() *listing {
+	l := &listing{}
+	for _, record := range []testRecord{
+		{Name: "a", Vendor: "foo", Running: true, Port: 8080, Load: 0.5, Created: "2021-05-02T10:00:00Z"},
+		{Name: "b", Vendor: "bar", Port: 9000, Load: 1.25, Created: "2021-05-01T10:00:00Z"},
+		{Name: "c", Vendor: "foo", Port: 10000, Created: "2021-05-03T10:00:00Z"},
+		{Name: "d", Running: true, Port: 80, Load: 0.5, Created: "2021-04-30T10:00:00Z"},
+	} {
+		l.add(record, record.Name)
+	}
+	return l
+}
+
+func names(l *listing) string {
+	var names []string
+	for _, row := range l.rows {
+		names = append(names, row.cells[0])
+	}
+	return strings.Join(names, ",")
+}
+
+func TestListingFilter(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+		err        bool
+	}{
+		{expression: "", want: "a,b,c,d"},
+		{expression: "vendor=foo", want: "a,c"},
+		{expression: "vendor=foo,running=true", want: "a"},
+		{expression: "running=false", want: "b,c"},
+		{expression: "vendor=", want: "d"},
+		{expression: "port=8080", want: "a"},
+		{expression: "load=0.5", want: "a,d"},
+		{expression: "load=0", want: "c"},
+		{expression: "name=a=b", want: ""},
+		{expression: "missing=x", want: ""},
+		{expression: "vendor", err: true},
+		{expression: "=foo", err: true},
+		{expression: "vendor=foo,", err: true},
+	}
+	for _, test := range tests {
+		l := testListing()
+		err := l.filter(test.expression)
+		if (err != nil) != test.err {
+			t.Errorf("filter(%q) error = %v, want error %v", test.expression, err, test.err)
+			continue
+		}
+		if !test.err && names(l) != test.want {
+			t.Errorf("filter(%q) = %q, want %q", test.expression, names(l), test.want)
+		}
+	}
+}
+
+func TestListingSort(t *testing.T) {
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{field: "", want: "a,b,c,d"},
+		{field: "name", want: "a,b,c,d"},
+		{field: "-name", want: "d,c,b,a"},
+		// numbers are compared by value, not by text
+		{field: "port", want: "d,a,b,c"},
+		{field: "-port", want: "c,b,a,d"},
+		{field: "created_at", want: "d,b,a,c"},
+		// missing fields come first, equal values keep their order
+		{field: "vendor", want: "d,b,a,c"},
+		{field: "load", want: "c,a,d,b"},
+		{field: "-load", want: "b,a,d,c"},
+		{field: "missing", want: "a,b,c,d"},
+	}
+	for _, test := range tests {
+		l := testListing()
+		l.sort(test.field)
+		if names(l) != test.want {
+			t.Errorf("sort(%q) = %q, want %q", test.field, names(l), test.want)
+		}
+	}
+}
+
+func TestListingFilterThenSort(t *testing.T) {
+	l := testListing()
+	if err := l.filter("running=true"); err != nil {
+		t.Fatal(err)
+	}
+	l.sort("-created_at")
+	if names(l) != "a,d" {
+		t.Errorf("got %q, want %q", names(l), "a,d")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2021 Xiaozhe Yao et al.
+//
+// This software is released under the MIT License.
+// https://opensource.org/licenses/MIT
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/autoai-org/aid/internal/runtime/cargo"
+	"github.com/dustin/go-humanize"
+)
+
+const progressBarWidth = 30
+
+// downloadRenderer draws one progress bar per download. The bars are redrawn
+// in place, once all of them are finished the next downloads are drawn below.
+type downloadRenderer struct {
+	w         io.Writer
+	order     []string
+	downloads map[string]cargo.DownloadEvent
+	// drawn is the number of lines to redraw
+	drawn int
+}
+
+func newDownloadRenderer(w io.Writer) *downloadRenderer {
+	return &downloadRenderer{w: w, downloads: make(map[string]cargo.DownloadEvent)}
+}
+
+func (r *downloadRenderer) render(event cargo.DownloadEvent) {
+	key := event.Package + " " + event.URL
+	if _, ok := r.downloads[key]; !ok {
+		r.order = append(r.order, key)
+	}
+	if r.drawn > 0 {
+		fmt.Fprintf(r.w, "\033[%dA", r.drawn)
+	}
+	r.downloads[key] = event
+	finished := true
+	for _, key := range r.order {
+		fmt.Fprintf(r.w, "\033[2K%s\n", progressLine(r.downloads[key]))
+		if state := r.downloads[key].State; state != cargo.DownloadDone && state != cargo.DownloadFailed {
+			finished = false
+		}
+	}
+	r.drawn = len(r.order)
+	if finished {
+		r.order, r.drawn = nil, 0
+		r.downloads = make(map[string]cargo.DownloadEvent)
+	}
+}
+
+func progressLine(event cargo.DownloadEvent) string {
+	name := event.Package + "/" + event.Name
+	if len(name) > 32 {
+		name = "..." + name[len(name)-29:]
+	}
+	filled := 0
+	percentage := "    "
+	size := humanize.Bytes(uint64(event.Downloaded))
+	if event.Total > 0 {
+		filled = int(event.Downloaded * progressBarWidth / event.Total)
+		if filled > progressBarWidth {
+			filled = progressBarWidth
+		}
+		percentage = fmt.Sprintf("%3d%%", event.Downloaded*100/event.Total)
+		size += "/" + humanize.Bytes(uint64(event.Total))
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+	state := event.State
+	if event.State == cargo.DownloadFailed {
+		state += ": " + event.Error
+	}
+	return fmt.Sprintf("%-32s [%s] %s %-20s %s", name, bar, percentage, size, state)
+}